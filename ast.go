@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+var astFormats = map[string]func(stmts []ast.Stmt) string{
+	"sexpr": internal.PrintStmts,
+	"tree":  internal.PrintTree,
+	"json":  printJSON,
+	"dot":   internal.PrintDot,
+}
+
+func runAst(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	mode := flags.String("format", "sexpr", "output format: sexpr, tree, json or dot")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	render, ok := astFormats[*mode]
+	if flags.NArg() != 1 || !ok {
+		fmt.Println("Usage: go-lox ast [-format sexpr|tree|json|dot] script")
+		return 64
+	}
+
+	stmts, err := parseFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Print(render(stmts))
+	return 0
+}
+
+// printJSON renders the program in the versioned schema of ast.EncodeJSON, the one parse --json writes.
+func printJSON(stmts []ast.Stmt) string {
+	bytes, err := ast.EncodeJSON(stmts)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return string(bytes)
+}
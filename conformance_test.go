@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The conformance suite runs Lox scripts annotated in the style of the reference implementation's test suite through
+// the go-lox command, and compares what it prints and its exit status with the annotations:
+//
+//	print 1; // expect: 1
+//	a = 1; // expect runtime error: Undefined variable 'a'.
+//	print; // Error at ';': Expect expression.
+//	// [line 3] Error: Unexpected character.
+//
+// Expected output lines must be printed to stdout in order. A runtime error is its message on stderr followed by
+// "[line N]", with exit status 70; compile errors are "[line N] Error..." lines on stderr, with exit status 65.
+//
+// The scripts are the files under testdata/conformance, grouped into features by directory, and the example scripts
+// of the repository. Scripts on which go-lox is known to deviate from the reference are listed, with the reason, in
+// testdata/conformance/deviations.txt; the test fails if any other script fails, or if a listed script passes.
+
+const (
+	conformanceDir = "testdata/conformance"
+	deviationsFile = "testdata/conformance/deviations.txt"
+
+	exitCompileError = 65
+	exitRuntimeError = 70
+)
+
+var (
+	expectOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectRuntimeError = regexp.MustCompile(`// expect runtime error: (.+)`)
+	expectError        = regexp.MustCompile(`// (Error.*)`)
+	expectErrorLine    = regexp.MustCompile(`// \[line (\d+)\] (Error.*)`)
+	runtimeErrorLine   = regexp.MustCompile(`^\[line (\d+)\]`)
+)
+
+// TestMain runs the go-lox command instead of the tests when the conformance test executes its own binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_LOX_CONFORMANCE") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// expectation is what the annotations of a script expect of running it.
+type expectation struct {
+	output       []string
+	errors       []string // compile errors, in order
+	runtimeError string
+	runtimeLine  int
+	exitCode     int
+}
+
+func parseExpectation(source string) expectation {
+	var e expectation
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if m := expectOutput.FindStringSubmatch(text); m != nil {
+			e.output = append(e.output, m[1])
+		} else if m := expectRuntimeError.FindStringSubmatch(text); m != nil {
+			e.runtimeError, e.runtimeLine, e.exitCode = m[1], line, exitRuntimeError
+		} else if m := expectErrorLine.FindStringSubmatch(text); m != nil {
+			e.errors = append(e.errors, fmt.Sprintf("[line %v] %v", m[1], m[2]))
+			e.exitCode = exitCompileError
+		} else if m := expectError.FindStringSubmatch(text); m != nil {
+			e.errors = append(e.errors, fmt.Sprintf("[line %v] %v", line, m[1]))
+			e.exitCode = exitCompileError
+		}
+	}
+	return e
+}
+
+// check returns how the result of running a script differs from e, or nil if it does not.
+func (e expectation) check(stdout, stderr string, exitCode int) []string {
+	var problems []string
+	output := splitOutput(stdout)
+	for i := 0; i < max(len(output), len(e.output)); i++ {
+		switch {
+		case i >= len(output):
+			problems = append(problems, fmt.Sprintf("missing output %q", e.output[i]))
+		case i >= len(e.output):
+			problems = append(problems, fmt.Sprintf("unexpected output %q", output[i]))
+		case output[i] != e.output[i]:
+			problems = append(problems, fmt.Sprintf("output %q, want %q", output[i], e.output[i]))
+		}
+	}
+
+	errs := splitOutput(stderr)
+	if e.runtimeError != "" {
+		switch {
+		case len(errs) < 2:
+			problems = append(problems, fmt.Sprintf("stderr %q, want runtime error %q", stderr, e.runtimeError))
+		case errs[0] != e.runtimeError:
+			problems = append(problems, fmt.Sprintf("runtime error %q, want %q", errs[0], e.runtimeError))
+		default:
+			m := runtimeErrorLine.FindStringSubmatch(errs[1])
+			if m == nil || m[1] != strconv.Itoa(e.runtimeLine) {
+				problems = append(problems, fmt.Sprintf("runtime error location %q, want [line %v]", errs[1], e.runtimeLine))
+			}
+		}
+	} else if strings.Join(errs, "\n") != strings.Join(e.errors, "\n") {
+		problems = append(problems, fmt.Sprintf("stderr %q, want %q", errs, e.errors))
+	}
+
+	if exitCode != e.exitCode {
+		problems = append(problems, fmt.Sprintf("exit status %v, want %v", exitCode, e.exitCode))
+	}
+	return problems
+}
+
+func splitOutput(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// conformanceScripts returns the scripts of the suite, by feature.
+func conformanceScripts(t *testing.T) map[string][]string {
+	scripts := map[string][]string{"examples": {"script.lox"}}
+	examples, err := filepath.Glob("scripts/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts["examples"] = append(scripts["examples"], examples...)
+
+	err = filepath.WalkDir(conformanceDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".lox" {
+			return err
+		}
+		feature := filepath.Base(filepath.Dir(path))
+		scripts[feature] = append(scripts[feature], filepath.ToSlash(path))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scripts
+}
+
+// readDeviations reads the scripts known to fail, one path per line, each optionally followed by a # comment.
+func readDeviations(t *testing.T) map[string]bool {
+	data, err := os.ReadFile(deviationsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviations := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if path := strings.TrimSpace(line); path != "" {
+			deviations[path] = true
+		}
+	}
+	return deviations
+}
+
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a go-lox process per script")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviations := readDeviations(t)
+	scripts := conformanceScripts(t)
+
+	features := make([]string, 0, len(scripts))
+	for feature := range scripts {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	var report strings.Builder
+	passed, total := 0, 0
+	for _, feature := range features {
+		featurePassed := 0
+		for _, path := range scripts[feature] {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(executable, path)
+			cmd.Env = append(os.Environ(), "GO_LOX_CONFORMANCE=1")
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			exitCode := 0
+			if err := cmd.Run(); err != nil {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					t.Fatal(err)
+				}
+				exitCode = exitErr.ExitCode()
+			}
+
+			problems := parseExpectation(string(source)).check(stdout.String(), stderr.String(), exitCode)
+			switch {
+			case len(problems) == 0 && deviations[path]:
+				t.Errorf("%v: passes, but is listed in %v", path, deviationsFile)
+			case len(problems) > 0 && !deviations[path]:
+				t.Errorf("%v:\n\t%v", path, strings.Join(problems, "\n\t"))
+			}
+			if len(problems) == 0 {
+				featurePassed++
+			}
+		}
+		fmt.Fprintf(&report, "%-18v %3v/%-3v %5.1f%%\n", feature, featurePassed, len(scripts[feature]),
+			100*float64(featurePassed)/float64(len(scripts[feature])))
+		passed, total = passed+featurePassed, total+len(scripts[feature])
+	}
+	fmt.Fprintf(&report, "%-18v %3v/%-3v %5.1f%%", "total", passed, total, 100*float64(passed)/float64(total))
+	t.Logf("conformance by feature:\n%v", report.String())
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/cover"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+)
+
+func runCover(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	htmlOut := flags.String("html", "", "write an annotated source listing to `file`")
+	lcovOut := flags.String("lcov", "", "write an LCOV tracefile to `file`")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: go-lox cover [-html file] [-lcov file] scripts...")
+		return 64
+	}
+
+	// the scripts' output goes to stdout, the summary to stderr
+	coverage := cover.New()
+	loader := newLoader(parse.Parse)
+	status := 0
+	for _, path := range flags.Args() {
+		stmts, err := parseFile(path)
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+		if _, err := coverage.Run(path, stmts, engine.WithLoader(loader), engine.AllowAll()); err != nil {
+			fmt.Printf("%v: %v\n", path, err)
+			status = 1
+		}
+	}
+
+	files := coverage.Files()
+	if err := cover.WriteText(os.Stderr, files); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *htmlOut != "" {
+		if err := writeFile(*htmlOut, func(w io.Writer) error { return cover.WriteHTML(w, files) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if *lcovOut != "" {
+		if err := writeFile(*lcovOut, func(w io.Writer) error { return cover.WriteLCOV(w, files) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/dap"
+	"github.com/brentellingson/go-lox/internal/parse"
+)
+
+func runDap(args []string) int {
+	if len(args) != 0 {
+		fmt.Println("Usage: go-lox dap")
+		return 64
+	}
+	if err := dap.NewServer(os.Stdin, os.Stdout, newLoader(parse.Parse)).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/debug"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func runDebug(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: go-lox debug script")
+		return 64
+	}
+
+	path := args[0]
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	tokens, err := scan.Scan(string(bytes))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	console := debug.NewConsole(path, string(bytes), os.Stdin, os.Stdout)
+	debugger := debug.New(console, true)
+	_, err = debugger.Run(stmts, engine.WithLoader(newLoader(parse.Parse)), engine.WithPath(path), engine.AllowAll())
+	if errors.Is(err, debug.ErrQuit) {
+		return 1
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/format"
+)
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with status 1 if there are any")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: go-lox fmt [-w] [-d] [-check] files...")
+		return 64
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+		source := string(bytes)
+		formatted, err := format.Source(source)
+		if err != nil {
+			fmt.Printf("%v: %v\n", path, err)
+			status = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != source {
+				fmt.Println(path)
+				status = 1
+			}
+		case *diff:
+			fmt.Print(format.Diff(path, source, formatted))
+		case *write:
+			if formatted != source {
+				if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+					fmt.Println(err)
+					status = 1
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
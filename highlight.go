@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/highlight"
+)
+
+func runHighlight(args []string) int {
+	flags := flag.NewFlagSet("highlight", flag.ContinueOnError)
+	mode := flags.String("format", "ansi", "output format: ansi or html")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 || (*mode != "ansi" && *mode != "html") {
+		fmt.Println("Usage: go-lox highlight [-format ansi|html] script")
+		return 64
+	}
+
+	bytes, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *mode == "html" {
+		fmt.Print(highlight.HTML(string(bytes)))
+	} else {
+		fmt.Print(highlight.ANSI(string(bytes)))
+	}
+	return 0
+}
+
+// isTerminal reports whether f is attached to a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -14,6 +14,9 @@ type ExprVisitor interface {
 	VisitVariableExpr(expr *Variable) (any, error)
 	VisitAssignExpr(expr *Assign) (any, error)
 	VisitLogicalExpr(expr *Logical) (any, error)
+	VisitGetExpr(expr *Get) (any, error)
+	VisitCallExpr(expr *Call) (any, error)
+	VisitLambdaExpr(expr *Lambda) (any, error)
 }
 
 type Binary struct {
@@ -77,3 +80,37 @@ type Logical struct {
 func (e *Logical) Accept(v ExprVisitor) (any, error) {
 	return v.VisitLogicalExpr(e)
 }
+
+type Get struct {
+	Object Expr
+	Name   token.Token
+}
+
+func (e *Get) Accept(v ExprVisitor) (any, error) {
+	return v.VisitGetExpr(e)
+}
+
+type Call struct {
+	Callee    Expr
+	Paren     token.Token
+	Arguments []Expr
+}
+
+func (e *Call) Accept(v ExprVisitor) (any, error) {
+	return v.VisitCallExpr(e)
+}
+
+// Lambda is an anonymous function, closing over the scope it is evaluated in: fun (params) { body }, or the arrow form
+// (params) => body. Keyword is the fun or the =>. An arrow function whose body is an expression is ExprBody; its Body
+// holds a single return of the expression, positioned at the =>. A lambda whose body yields is a Generator.
+type Lambda struct {
+	Keyword   token.Token
+	Params    []token.Token
+	Body      *Block
+	ExprBody  bool
+	Generator bool
+}
+
+func (e *Lambda) Accept(v ExprVisitor) (any, error) {
+	return v.VisitLambdaExpr(e)
+}
@@ -0,0 +1,557 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// JSONVersion is the version of the schema written by EncodeJSON. It changes whenever a node or field is renamed or
+// removed, or when a new field changes how a document is run; DecodeJSON rejects documents of any other version.
+const JSONVersion = 2
+
+// The schema is a document {"version": 1, "statements": [...]} in which every node is an object whose "type" names
+// the Go type of the node and whose other keys are its fields in lower camel case. Tokens are objects
+// {"type": "PLUS", "lexeme": "+", "literal": null, "line": 1, "column": 3}, or null where a node has no token. Literal values are
+// JSON numbers, strings, booleans or null.
+type jsonDocument struct {
+	Version    int               `json:"version"`
+	Statements []json.RawMessage `json:"statements"`
+}
+
+type jsonToken struct {
+	Type    string `json:"type"`
+	Lexeme  string `json:"lexeme"`
+	Literal any    `json:"literal"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// EncodeJSON converts stmts to a versioned JSON document.
+func EncodeJSON(stmts []Stmt) ([]byte, error) {
+	e := &jsonEncoder{}
+	doc := map[string]any{"version": JSONVersion, "statements": e.stmts(stmts)}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// DecodeJSON rebuilds the statements of a document written by EncodeJSON.
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST version %v", doc.Version)
+	}
+	return decodeStmts(doc.Statements)
+}
+
+type jsonEncoder struct{}
+
+func (e *jsonEncoder) stmt(stmt Stmt) any {
+	if stmt == nil {
+		return nil
+	}
+	v, _ := stmt.Accept(e)
+	return v
+}
+
+func (e *jsonEncoder) stmts(stmts []Stmt) []any {
+	rslt := make([]any, len(stmts))
+	for i, s := range stmts {
+		rslt[i] = e.stmt(s)
+	}
+	return rslt
+}
+
+func (e *jsonEncoder) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+	v, _ := expr.Accept(e)
+	return v
+}
+
+func (e *jsonEncoder) token(t token.Token) any {
+	if t == (token.Token{}) {
+		return nil
+	}
+	return jsonToken{Type: t.Type.String(), Lexeme: t.Lexeme, Literal: t.Literal, Line: t.Line, Column: t.Column}
+}
+
+func (e *jsonEncoder) exprs(exprs []Expr) []any {
+	rslt := make([]any, len(exprs))
+	for i, x := range exprs {
+		rslt[i] = e.expr(x)
+	}
+	return rslt
+}
+
+func (e *jsonEncoder) tokens(ts []token.Token) []any {
+	rslt := make([]any, len(ts))
+	for i, t := range ts {
+		rslt[i] = e.token(t)
+	}
+	return rslt
+}
+
+func (e *jsonEncoder) VisitExpressionStmt(stmt *Expression) (any, error) {
+	return map[string]any{"type": "Expression", "expression": e.expr(stmt.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitPrintStmt(stmt *Print) (any, error) {
+	return map[string]any{"type": "Print", "keyword": e.token(stmt.Keyword), "expression": e.expr(stmt.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitVarStmt(stmt *Var) (any, error) {
+	return map[string]any{"type": "Var", "name": e.token(stmt.Name), "expression": e.expr(stmt.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitBlockStmt(stmt *Block) (any, error) {
+	return map[string]any{
+		"type":       "Block",
+		"leftBrace":  e.token(stmt.LeftBrace),
+		"statements": e.stmts(stmt.Statements),
+		"rightBrace": e.token(stmt.RightBrace),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitIfStmt(stmt *If) (any, error) {
+	return map[string]any{
+		"type":       "If",
+		"keyword":    e.token(stmt.Keyword),
+		"condition":  e.expr(stmt.Condition),
+		"thenBranch": e.stmt(stmt.ThenBranch),
+		"elseBranch": e.stmt(stmt.ElseBranch),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitWhileStmt(stmt *While) (any, error) {
+	return map[string]any{
+		"type":      "While",
+		"keyword":   e.token(stmt.Keyword),
+		"condition": e.expr(stmt.Condition),
+		"body":      e.stmt(stmt.Body),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitImportStmt(stmt *Import) (any, error) {
+	return map[string]any{
+		"type":    "Import",
+		"keyword": e.token(stmt.Keyword),
+		"path":    e.token(stmt.Path),
+		"alias":   e.token(stmt.Alias),
+		"names":   e.tokens(stmt.Names),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitFunctionStmt(stmt *Function) (any, error) {
+	return map[string]any{
+		"type":      "Function",
+		"keyword":   e.token(stmt.Keyword),
+		"name":      e.token(stmt.Name),
+		"params":    e.tokens(stmt.Params),
+		"body":      e.stmt(stmt.Body),
+		"generator": stmt.Generator,
+	}, nil
+}
+
+func (e *jsonEncoder) VisitReturnStmt(stmt *Return) (any, error) {
+	return map[string]any{"type": "Return", "keyword": e.token(stmt.Keyword), "value": e.expr(stmt.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitForStmt(stmt *For) (any, error) {
+	return map[string]any{
+		"type":     "For",
+		"keyword":  e.token(stmt.Keyword),
+		"name":     e.token(stmt.Name),
+		"iterable": e.expr(stmt.Iterable),
+		"body":     e.stmt(stmt.Body),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitYieldStmt(stmt *Yield) (any, error) {
+	return map[string]any{"type": "Yield", "keyword": e.token(stmt.Keyword), "value": e.expr(stmt.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitBinaryExpr(expr *Binary) (any, error) {
+	return map[string]any{
+		"type":     "Binary",
+		"left":     e.expr(expr.Left),
+		"operator": e.token(expr.Operator),
+		"right":    e.expr(expr.Right),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitGroupingExpr(expr *Grouping) (any, error) {
+	return map[string]any{"type": "Grouping", "expression": e.expr(expr.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitLiteralExpr(expr *Literal) (any, error) {
+	return map[string]any{"type": "Literal", "value": expr.Value}, nil
+}
+
+func (e *jsonEncoder) VisitUnaryExpr(expr *Unary) (any, error) {
+	return map[string]any{"type": "Unary", "operator": e.token(expr.Operator), "right": e.expr(expr.Right)}, nil
+}
+
+func (e *jsonEncoder) VisitVariableExpr(expr *Variable) (any, error) {
+	return map[string]any{"type": "Variable", "name": e.token(expr.Name)}, nil
+}
+
+func (e *jsonEncoder) VisitAssignExpr(expr *Assign) (any, error) {
+	return map[string]any{"type": "Assign", "name": e.token(expr.Name), "value": e.expr(expr.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitLogicalExpr(expr *Logical) (any, error) {
+	return map[string]any{
+		"type":     "Logical",
+		"left":     e.expr(expr.Left),
+		"operator": e.token(expr.Operator),
+		"right":    e.expr(expr.Right),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitGetExpr(expr *Get) (any, error) {
+	return map[string]any{"type": "Get", "object": e.expr(expr.Object), "name": e.token(expr.Name)}, nil
+}
+
+func (e *jsonEncoder) VisitCallExpr(expr *Call) (any, error) {
+	return map[string]any{
+		"type":      "Call",
+		"callee":    e.expr(expr.Callee),
+		"paren":     e.token(expr.Paren),
+		"arguments": e.exprs(expr.Arguments),
+	}, nil
+}
+
+func (e *jsonEncoder) VisitLambdaExpr(expr *Lambda) (any, error) {
+	return map[string]any{
+		"type":      "Lambda",
+		"keyword":   e.token(expr.Keyword),
+		"params":    e.tokens(expr.Params),
+		"body":      e.stmt(expr.Body),
+		"exprBody":  expr.ExprBody,
+		"generator": expr.Generator,
+	}, nil
+}
+
+// jsonNode holds the fields of one node until its type is known.
+type jsonNode map[string]json.RawMessage
+
+func (n jsonNode) stmt(key string) (Stmt, error) {
+	return decodeStmt(n[key])
+}
+
+// requiredStmt decodes a statement the node cannot do without.
+func (n jsonNode) requiredStmt(key string) (Stmt, error) {
+	st, err := decodeStmt(n[key])
+	if err == nil && st == nil {
+		return nil, fmt.Errorf("%v: expected a statement", key)
+	}
+	return st, err
+}
+
+func (n jsonNode) stmts(key string) ([]Stmt, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(n[key], &raw); err != nil {
+		return nil, fmt.Errorf("%v: %w", key, err)
+	}
+	return decodeStmts(raw)
+}
+
+func (n jsonNode) expr(key string) (Expr, error) {
+	return decodeExpr(n[key])
+}
+
+// requiredExpr decodes an expression the node cannot do without.
+func (n jsonNode) requiredExpr(key string) (Expr, error) {
+	x, err := decodeExpr(n[key])
+	if err == nil && x == nil {
+		return nil, fmt.Errorf("%v: expected an expression", key)
+	}
+	return x, err
+}
+
+func (n jsonNode) exprs(key string) ([]Expr, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(n[key], &raw); err != nil {
+		return nil, fmt.Errorf("%v: %w", key, err)
+	}
+	var exprs []Expr
+	for _, r := range raw {
+		x, err := decodeExpr(r)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil {
+			return nil, fmt.Errorf("%v: expected an expression", key)
+		}
+		exprs = append(exprs, x)
+	}
+	return exprs, nil
+}
+
+func (n jsonNode) block(key string) (*Block, error) {
+	s, err := decodeStmt(n[key])
+	if err != nil {
+		return nil, err
+	}
+	b, ok := s.(*Block)
+	if !ok {
+		return nil, fmt.Errorf("%v: expected a Block", key)
+	}
+	return b, nil
+}
+
+// bool decodes a flag, which is false if absent.
+func (n jsonNode) bool(key string) (bool, error) {
+	var b bool
+	if raw, ok := n[key]; ok {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return false, fmt.Errorf("%v: %w", key, err)
+		}
+	}
+	return b, nil
+}
+
+func (n jsonNode) token(key string) (token.Token, error) {
+	return decodeToken(n[key])
+}
+
+func (n jsonNode) tokens(key string) ([]token.Token, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(n[key], &raw); err != nil {
+		return nil, fmt.Errorf("%v: %w", key, err)
+	}
+	var ts []token.Token
+	for _, r := range raw {
+		t, err := decodeToken(r)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func decodeNode(data json.RawMessage) (jsonNode, string, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, "", err
+	}
+	var typ string
+	if err := json.Unmarshal(n["type"], &typ); err != nil {
+		return nil, "", fmt.Errorf("node without type: %w", err)
+	}
+	return n, typ, nil
+}
+
+func decodeToken(data json.RawMessage) (token.Token, error) {
+	if isNull(data) {
+		return token.Token{}, nil
+	}
+	var t jsonToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return token.Token{}, err
+	}
+	for tt := token.TokenType(0); tt <= token.EOF; tt++ {
+		if tt.String() == t.Type {
+			return token.NewToken(tt, t.Lexeme, t.Literal, t.Line, t.Column), nil
+		}
+	}
+	return token.Token{}, fmt.Errorf("unknown token type %q", t.Type)
+}
+
+func decodeStmts(raw []json.RawMessage) ([]Stmt, error) {
+	var stmts []Stmt
+	for _, r := range raw {
+		s, err := decodeStmt(r)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, errors.New("expected a statement")
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func decodeStmt(data json.RawMessage) (Stmt, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	n, typ, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs [5]error
+	switch typ {
+	case "Expression":
+		s := &Expression{}
+		s.Expression, errs[0] = n.requiredExpr("expression")
+		return s, firstError(errs[:]...)
+	case "Print":
+		s := &Print{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Expression, errs[1] = n.requiredExpr("expression")
+		return s, firstError(errs[:]...)
+	case "Var":
+		s := &Var{}
+		s.Name, errs[0] = n.token("name")
+		s.Expression, errs[1] = n.expr("expression")
+		return s, firstError(errs[:]...)
+	case "Block":
+		s := &Block{}
+		s.LeftBrace, errs[0] = n.token("leftBrace")
+		s.Statements, errs[1] = n.stmts("statements")
+		s.RightBrace, errs[2] = n.token("rightBrace")
+		return s, firstError(errs[:]...)
+	case "If":
+		s := &If{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Condition, errs[1] = n.requiredExpr("condition")
+		s.ThenBranch, errs[2] = n.requiredStmt("thenBranch")
+		s.ElseBranch, errs[3] = n.stmt("elseBranch")
+		return s, firstError(errs[:]...)
+	case "While":
+		s := &While{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Condition, errs[1] = n.requiredExpr("condition")
+		s.Body, errs[2] = n.requiredStmt("body")
+		return s, firstError(errs[:]...)
+	case "Import":
+		s := &Import{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Path, errs[1] = n.token("path")
+		s.Alias, errs[2] = n.token("alias")
+		s.Names, errs[3] = n.tokens("names")
+		return s, firstError(errs[:]...)
+	case "Function":
+		s := &Function{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Name, errs[1] = n.token("name")
+		s.Params, errs[2] = n.tokens("params")
+		s.Body, errs[3] = n.block("body")
+		s.Generator, errs[4] = n.bool("generator")
+		return s, firstError(errs[:]...)
+	case "Return":
+		s := &Return{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Value, errs[1] = n.expr("value")
+		return s, firstError(errs[:]...)
+	case "For":
+		s := &For{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Name, errs[1] = n.token("name")
+		s.Iterable, errs[2] = n.requiredExpr("iterable")
+		s.Body, errs[3] = n.requiredStmt("body")
+		return s, firstError(errs[:]...)
+	case "Yield":
+		s := &Yield{}
+		s.Keyword, errs[0] = n.token("keyword")
+		s.Value, errs[1] = n.expr("value")
+		return s, firstError(errs[:]...)
+	}
+	return nil, fmt.Errorf("unknown statement type %q", typ)
+}
+
+func decodeExpr(data json.RawMessage) (Expr, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	n, typ, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs [5]error
+	switch typ {
+	case "Binary":
+		e := &Binary{}
+		e.Left, errs[0] = n.requiredExpr("left")
+		e.Operator, errs[1] = n.token("operator")
+		e.Right, errs[2] = n.requiredExpr("right")
+		return e, firstError(errs[:]...)
+	case "Grouping":
+		e := &Grouping{}
+		e.Expression, errs[0] = n.requiredExpr("expression")
+		return e, firstError(errs[:]...)
+	case "Literal":
+		e := &Literal{}
+		if !isNull(n["value"]) {
+			errs[0] = json.Unmarshal(n["value"], &e.Value)
+		}
+		return e, firstError(errs[:]...)
+	case "Unary":
+		e := &Unary{}
+		e.Operator, errs[0] = n.token("operator")
+		e.Right, errs[1] = n.requiredExpr("right")
+		return e, firstError(errs[:]...)
+	case "Variable":
+		e := &Variable{}
+		e.Name, errs[0] = n.token("name")
+		return e, firstError(errs[:]...)
+	case "Assign":
+		e := &Assign{}
+		e.Name, errs[0] = n.token("name")
+		e.Value, errs[1] = n.requiredExpr("value")
+		return e, firstError(errs[:]...)
+	case "Logical":
+		e := &Logical{}
+		e.Left, errs[0] = n.requiredExpr("left")
+		e.Operator, errs[1] = n.token("operator")
+		e.Right, errs[2] = n.requiredExpr("right")
+		return e, firstError(errs[:]...)
+	case "Get":
+		e := &Get{}
+		e.Object, errs[0] = n.requiredExpr("object")
+		e.Name, errs[1] = n.token("name")
+		return e, firstError(errs[:]...)
+	case "Call":
+		e := &Call{}
+		e.Callee, errs[0] = n.requiredExpr("callee")
+		e.Paren, errs[1] = n.token("paren")
+		e.Arguments, errs[2] = n.exprs("arguments")
+		return e, firstError(errs[:]...)
+	case "Lambda":
+		e := &Lambda{}
+		e.Keyword, errs[0] = n.token("keyword")
+		e.Params, errs[1] = n.tokens("params")
+		e.Body, errs[2] = n.block("body")
+		e.ExprBody, errs[3] = n.bool("exprBody")
+		e.Generator, errs[4] = n.bool("generator")
+		if e.ExprBody && e.Body != nil {
+			if ret, ok := singleReturn(e.Body); !ok || ret.Value == nil {
+				errs[3] = errors.New("exprBody: body is not a single return of a value")
+			}
+		}
+		return e, firstError(errs[:]...)
+	}
+	return nil, fmt.Errorf("unknown expression type %q", typ)
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package ast_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// everyNode is a program containing every type of node.
+const everyNode = `
+import "lib" as lib;
+from "lib" import a, b;
+var x = -1 + 2 * (3 - 4);
+var s = "two
+lines";
+print !true or false and nil;
+x = lib.f(x, "y");
+{ var y; }
+if (x > 1) print x; else print -x;
+while (x < 10) x = x + 1;
+fun f(a, b) { return a; }
+fun g() { for (c in "abc") yield c; yield; return; }
+var h = fun (a) { return a; };
+var k = (a, b) => a + b;
+var m = (a) => { return a; };
+`
+
+func TestJSONRoundTrip(t *testing.T) {
+	sources := map[string]string{"every node": everyNode}
+	paths, err := filepath.Glob("../../testdata/conformance/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[path] = string(source)
+	}
+
+	seen := map[string]bool{}
+	for name, source := range sources {
+		tokens, err := scan.Scan(source)
+		if err != nil {
+			continue
+		}
+		stmts, err := parse.Parse(tokens)
+		if err != nil {
+			continue // some conformance scripts test parse errors
+		}
+		ast.Inspect(stmts, func(n ast.Node) bool {
+			if n != nil {
+				seen[fmt.Sprintf("%T", n)] = true
+			}
+			return true
+		})
+
+		data, err := ast.EncodeJSON(stmts)
+		if err != nil {
+			t.Fatalf("%v: EncodeJSON() error = %v", name, err)
+		}
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("%v: DecodeJSON() error = %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded, stmts) {
+			t.Errorf("%v: decoded statements differ from the original:\n%s", name, data)
+		}
+	}
+
+	for _, n := range []ast.Node{
+		&ast.Expression{}, &ast.Print{}, &ast.Var{}, &ast.Block{}, &ast.If{}, &ast.While{}, &ast.Import{},
+		&ast.Function{}, &ast.Return{}, &ast.For{}, &ast.Yield{}, &ast.Binary{}, &ast.Grouping{}, &ast.Literal{},
+		&ast.Unary{}, &ast.Variable{}, &ast.Assign{}, &ast.Logical{}, &ast.Get{}, &ast.Call{}, &ast.Lambda{},
+	} {
+		if name := fmt.Sprintf("%T", n); !seen[name] {
+			t.Errorf("no test program contains a %v", name)
+		}
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", "{"},
+		{"wrong version", `{"version": 0, "statements": []}`},
+		{"unknown node", `{"version": 2, "statements": [{"type": "Class"}]}`},
+		{"null statement", `{"version": 2, "statements": [null]}`},
+		{"binary without operands", `{"version": 2, "statements": [{"type": "Expression", "expression": {"type": "Binary"}}]}`},
+		{"print without expression", `{"version": 2, "statements": [{"type": "Print"}]}`},
+		{"if without branch", `{"version": 2, "statements": [{"type": "If", "condition": {"type": "Literal"}}]}`},
+		{"while without body", `{"version": 2, "statements": [{"type": "While", "condition": {"type": "Literal"}}]}`},
+		{"function without body", `{"version": 2, "statements": [{"type": "Function", "params": []}]}`},
+		{"null argument", `{"version": 2, "statements": [{"type": "Expression",
+			"expression": {"type": "Call", "callee": {"type": "Variable"}, "arguments": [null]}}]}`},
+		{"expression body not a return", `{"version": 2, "statements": [{"type": "Expression",
+			"expression": {"type": "Lambda", "params": [], "exprBody": true,
+				"body": {"type": "Block", "statements": [{"type": "Print", "expression": {"type": "Literal"}}]}}}]}`},
+		{"expression body without value", `{"version": 2, "statements": [{"type": "Expression",
+			"expression": {"type": "Lambda", "params": [], "exprBody": true,
+				"body": {"type": "Block", "statements": [{"type": "Return"}]}}}]}`},
+	}
+	for _, tt := range tests {
+		if _, err := ast.DecodeJSON([]byte(tt.data)); err == nil {
+			t.Errorf("%v: DecodeJSON() error = nil, want an error", tt.name)
+		}
+	}
+}
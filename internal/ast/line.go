@@ -0,0 +1,136 @@
+package ast
+
+import "strings"
+
+// StmtLine returns the source line on which stmt begins, or 0 if it is not known.
+func StmtLine(stmt Stmt) int {
+	switch s := stmt.(type) {
+	case *Expression:
+		return ExprLine(s.Expression)
+	case *Print:
+		return s.Keyword.Line
+	case *Var:
+		return s.Name.Line
+	case *Block:
+		return s.LeftBrace.Line
+	case *If:
+		return s.Keyword.Line
+	case *While:
+		return s.Keyword.Line
+	case *Import:
+		return s.Keyword.Line
+	case *Function:
+		return s.Keyword.Line
+	case *Return:
+		return s.Keyword.Line
+	case *For:
+		return s.Keyword.Line
+	case *Yield:
+		return s.Keyword.Line
+	}
+	return 0
+}
+
+// ExprLine returns the source line on which expr begins, or 0 if it is not known. Literals carry no position.
+func ExprLine(expr Expr) int {
+	switch e := expr.(type) {
+	case *Binary:
+		return ExprLine(e.Left)
+	case *Grouping:
+		return ExprLine(e.Expression)
+	case *Unary:
+		return e.Operator.Line
+	case *Variable:
+		return e.Name.Line
+	case *Assign:
+		return e.Name.Line
+	case *Logical:
+		return ExprLine(e.Left)
+	case *Get:
+		return ExprLine(e.Object)
+	case *Call:
+		return ExprLine(e.Callee)
+	case *Lambda:
+		return e.Keyword.Line
+	}
+	return 0
+}
+
+// StmtEndLine returns the source line on which stmt ends, or 0 if it is not known. Literals carry no position, so a
+// string literal spanning lines is taken to start on the line of the token before it, as it does in formatted source.
+func StmtEndLine(stmt Stmt) int {
+	switch s := stmt.(type) {
+	case *Expression:
+		return exprEndLine(s.Expression, ExprLine(s.Expression))
+	case *Print:
+		return exprEndLine(s.Expression, s.Keyword.Line)
+	case *Var:
+		if s.Expression == nil {
+			return s.Name.Line
+		}
+		return exprEndLine(s.Expression, s.Name.Line)
+	case *Block:
+		return s.RightBrace.Line
+	case *If:
+		if s.ElseBranch != nil {
+			return StmtEndLine(s.ElseBranch)
+		}
+		return StmtEndLine(s.ThenBranch)
+	case *While:
+		return StmtEndLine(s.Body)
+	case *Import:
+		line := max(s.Path.Line, s.Alias.Line)
+		for _, name := range s.Names {
+			line = max(line, name.Line)
+		}
+		return line
+	case *Function:
+		return s.Body.RightBrace.Line
+	case *Return:
+		if s.Value == nil {
+			return s.Keyword.Line
+		}
+		return exprEndLine(s.Value, s.Keyword.Line)
+	case *For:
+		return StmtEndLine(s.Body)
+	case *Yield:
+		if s.Value == nil {
+			return s.Keyword.Line
+		}
+		return exprEndLine(s.Value, s.Keyword.Line)
+	}
+	return 0
+}
+
+// exprEndLine returns the source line on which expr ends, given the line of the token before it.
+func exprEndLine(expr Expr, line int) int {
+	switch e := expr.(type) {
+	case *Binary:
+		return exprEndLine(e.Right, max(exprEndLine(e.Left, line), e.Operator.Line))
+	case *Grouping:
+		return exprEndLine(e.Expression, line)
+	case *Literal:
+		if s, ok := e.Value.(string); ok && line > 0 {
+			return line + strings.Count(s, "\n")
+		}
+		return line
+	case *Unary:
+		return exprEndLine(e.Right, e.Operator.Line)
+	case *Variable:
+		return e.Name.Line
+	case *Assign:
+		return exprEndLine(e.Value, e.Name.Line)
+	case *Logical:
+		return exprEndLine(e.Right, max(exprEndLine(e.Left, line), e.Operator.Line))
+	case *Get:
+		return max(exprEndLine(e.Object, line), e.Name.Line)
+	case *Call:
+		return max(exprEndLine(e.Callee, line), e.Paren.Line)
+	case *Lambda:
+		if e.ExprBody {
+			return exprEndLine(e.Body.Statements[0].(*Return).Value, e.Keyword.Line)
+		}
+		return e.Body.RightBrace.Line
+	}
+	return line
+}
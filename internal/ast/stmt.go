@@ -13,9 +13,15 @@ type StmtVisitor interface {
 	VisitBlockStmt(stmt *Block) (any, error)
 	VisitIfStmt(stmt *If) (any, error)
 	VisitWhileStmt(stmt *While) (any, error)
+	VisitImportStmt(stmt *Import) (any, error)
+	VisitFunctionStmt(stmt *Function) (any, error)
+	VisitReturnStmt(stmt *Return) (any, error)
+	VisitForStmt(stmt *For) (any, error)
+	VisitYieldStmt(stmt *Yield) (any, error)
 }
 
 type Print struct {
+	Keyword    token.Token
 	Expression Expr
 }
 
@@ -41,7 +47,9 @@ func (e *Var) Accept(v StmtVisitor) (any, error) {
 }
 
 type Block struct {
+	LeftBrace  token.Token
 	Statements []Stmt
+	RightBrace token.Token
 }
 
 func (e *Block) Accept(v StmtVisitor) (any, error) {
@@ -49,6 +57,7 @@ func (e *Block) Accept(v StmtVisitor) (any, error) {
 }
 
 type If struct {
+	Keyword    token.Token
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
@@ -59,6 +68,7 @@ func (e *If) Accept(v StmtVisitor) (any, error) {
 }
 
 type While struct {
+	Keyword   token.Token
 	Condition Expr
 	Body      Stmt
 }
@@ -66,3 +76,63 @@ type While struct {
 func (e *While) Accept(v StmtVisitor) (any, error) {
 	return v.VisitWhileStmt(e)
 }
+
+// Import binds a module loaded from Path. With Names empty, the module object itself is bound to Alias
+// (import "path" as alias;); otherwise each of Names is bound to the matching export (from "path" import a, b;).
+type Import struct {
+	Keyword token.Token
+	Path    token.Token
+	Alias   token.Token
+	Names   []token.Token
+}
+
+func (e *Import) Accept(v StmtVisitor) (any, error) {
+	return v.VisitImportStmt(e)
+}
+
+// Function is a named function declaration. A function whose body yields, outside of the functions nested in it, is a
+// Generator; the parser decides this, so it holds even once an optimizer removes the yield.
+type Function struct {
+	Keyword   token.Token
+	Name      token.Token
+	Params    []token.Token
+	Body      *Block
+	Generator bool
+}
+
+func (e *Function) Accept(v StmtVisitor) (any, error) {
+	return v.VisitFunctionStmt(e)
+}
+
+// Return leaves the enclosing function with the value of Value, or nil when Value is nil.
+type Return struct {
+	Keyword token.Token
+	Value   Expr
+}
+
+func (e *Return) Accept(v StmtVisitor) (any, error) {
+	return v.VisitReturnStmt(e)
+}
+
+// For runs Body once for each value of Iterable, bound to Name in a scope of its own
+// (for (name in iterable) body).
+type For struct {
+	Keyword  token.Token
+	Name     token.Token
+	Iterable Expr
+	Body     Stmt
+}
+
+func (e *For) Accept(v StmtVisitor) (any, error) {
+	return v.VisitForStmt(e)
+}
+
+// Yield suspends the enclosing function, a generator, producing the value of Value, or nil when Value is nil.
+type Yield struct {
+	Keyword token.Token
+	Value   Expr
+}
+
+func (e *Yield) Accept(v StmtVisitor) (any, error) {
+	return v.VisitYieldStmt(e)
+}
@@ -0,0 +1,282 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// Node is any Stmt or Expr.
+type Node any
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If the result visitor w is not nil, Walk
+// visits each of the children of node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order, starting with a call of v.Visit(node). The root may also be a
+// []Stmt, in which case each statement is walked in turn.
+func Walk(v Visitor, node Node) {
+	if stmts, ok := node.([]Stmt); ok {
+		walkList(v, stmts)
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Expression:
+		Walk(v, n.Expression)
+	case *Print:
+		Walk(v, n.Expression)
+	case *Var:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *Block:
+		walkList(v, n.Statements)
+	case *If:
+		Walk(v, n.Condition)
+		Walk(v, n.ThenBranch)
+		if n.ElseBranch != nil {
+			Walk(v, n.ElseBranch)
+		}
+	case *While:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+	case *Import:
+		// no child nodes
+	case *Function:
+		Walk(v, n.Body)
+	case *Return:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *For:
+		Walk(v, n.Iterable)
+		Walk(v, n.Body)
+	case *Yield:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *Binary:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *Grouping:
+		Walk(v, n.Expression)
+	case *Literal, *Variable:
+		// no child nodes
+	case *Unary:
+		Walk(v, n.Right)
+	case *Assign:
+		Walk(v, n.Value)
+	case *Logical:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *Get:
+		Walk(v, n.Object)
+	case *Call:
+		Walk(v, n.Callee)
+		for _, arg := range n.Arguments {
+			Walk(v, arg)
+		}
+	case *Lambda:
+		Walk(v, n.Body)
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, stmts []Stmt) {
+	for _, s := range stmts {
+		Walk(v, s)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order, calling f(node) for each node and, after a node's children,
+// f(nil). If f returns false, the children of node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite returns a copy of a syntax tree in which every node has been replaced, bottom-up, by the result of f. Each
+// node is passed to f after its children have been rewritten, as a fresh shallow copy, so f may modify it freely and
+// the original tree is never changed. The root may be a []Stmt, in which case a []Stmt is returned. A statement for
+// which f returns nil is removed from its enclosing program or block; the body of an if, while or for statement is
+// replaced by an empty block instead.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if stmts, ok := node.([]Stmt); ok {
+		return rewriteList(stmts, f)
+	}
+
+	switch n := node.(type) {
+	case *Expression:
+		c := *n
+		c.Expression = rewriteExpr(c.Expression, f)
+		return f(&c)
+	case *Print:
+		c := *n
+		c.Expression = rewriteExpr(c.Expression, f)
+		return f(&c)
+	case *Var:
+		c := *n
+		c.Expression = rewriteExpr(c.Expression, f)
+		return f(&c)
+	case *Block:
+		c := *n
+		c.Statements = rewriteList(c.Statements, f)
+		return f(&c)
+	case *If:
+		c := *n
+		c.Condition = rewriteExpr(c.Condition, f)
+		c.ThenBranch = rewriteBody(c.ThenBranch, f)
+		c.ElseBranch = rewriteStmt(c.ElseBranch, f)
+		return f(&c)
+	case *While:
+		c := *n
+		c.Condition = rewriteExpr(c.Condition, f)
+		c.Body = rewriteBody(c.Body, f)
+		return f(&c)
+	case *Import:
+		c := *n
+		return f(&c)
+	case *Function:
+		c := *n
+		// a function keeps its body even if f removes the block
+		if body, ok := rewriteStmt(c.Body, f).(*Block); ok {
+			c.Body = body
+		} else {
+			c.Body = &Block{LeftBrace: n.Body.LeftBrace, RightBrace: n.Body.RightBrace}
+		}
+		return f(&c)
+	case *Return:
+		c := *n
+		c.Value = rewriteExpr(c.Value, f)
+		return f(&c)
+	case *For:
+		c := *n
+		c.Iterable = rewriteExpr(c.Iterable, f)
+		c.Body = rewriteBody(c.Body, f)
+		return f(&c)
+	case *Yield:
+		c := *n
+		c.Value = rewriteExpr(c.Value, f)
+		return f(&c)
+	case *Binary:
+		c := *n
+		c.Left = rewriteExpr(c.Left, f)
+		c.Right = rewriteExpr(c.Right, f)
+		return f(&c)
+	case *Grouping:
+		c := *n
+		c.Expression = rewriteExpr(c.Expression, f)
+		return f(&c)
+	case *Literal:
+		c := *n
+		return f(&c)
+	case *Unary:
+		c := *n
+		c.Right = rewriteExpr(c.Right, f)
+		return f(&c)
+	case *Variable:
+		c := *n
+		return f(&c)
+	case *Assign:
+		c := *n
+		c.Value = rewriteExpr(c.Value, f)
+		return f(&c)
+	case *Logical:
+		c := *n
+		c.Left = rewriteExpr(c.Left, f)
+		c.Right = rewriteExpr(c.Right, f)
+		return f(&c)
+	case *Get:
+		c := *n
+		c.Object = rewriteExpr(c.Object, f)
+		return f(&c)
+	case *Call:
+		c := *n
+		c.Callee = rewriteExpr(c.Callee, f)
+		c.Arguments = make([]Expr, len(n.Arguments))
+		for i, arg := range n.Arguments {
+			c.Arguments[i] = rewriteExpr(arg, f)
+		}
+		return f(&c)
+	case *Lambda:
+		c := *n
+		// like a function, a lambda keeps its body even if f removes the block
+		if body, ok := rewriteStmt(c.Body, f).(*Block); ok {
+			c.Body = body
+		} else {
+			c.Body = &Block{LeftBrace: n.Body.LeftBrace, RightBrace: n.Body.RightBrace}
+		}
+		// the body stays an expression only while it is still a single return of one
+		if c.ExprBody {
+			ret, ok := singleReturn(c.Body)
+			c.ExprBody = ok && ret.Value != nil
+		}
+		return f(&c)
+	}
+	panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", node))
+}
+
+// singleReturn returns the statement of a block holding only a return.
+func singleReturn(b *Block) (*Return, bool) {
+	if len(b.Statements) != 1 {
+		return nil, false
+	}
+	ret, ok := b.Statements[0].(*Return)
+	return ret, ok
+}
+
+func rewriteList(stmts []Stmt, f func(Node) Node) []Stmt {
+	var rslt []Stmt
+	for _, s := range stmts {
+		if s := rewriteStmt(s, f); s != nil {
+			rslt = append(rslt, s)
+		}
+	}
+	return rslt
+}
+
+// rewriteBody rewrites the body of an if, while or for statement, which must not be nil.
+func rewriteBody(stmt Stmt, f func(Node) Node) Stmt {
+	if rslt := rewriteStmt(stmt, f); rslt != nil {
+		return rslt
+	}
+	return &Block{
+		LeftBrace:  token.Token{Type: token.LEFT_BRACE, Lexeme: "{", Line: StmtLine(stmt)},
+		RightBrace: token.Token{Type: token.RIGHT_BRACE, Lexeme: "}", Line: StmtEndLine(stmt)},
+	}
+}
+
+func rewriteStmt(stmt Stmt, f func(Node) Node) Stmt {
+	if stmt == nil {
+		return nil
+	}
+	rslt := Rewrite(stmt, f)
+	if rslt == nil {
+		return nil
+	}
+	return rslt.(Stmt)
+}
+
+func rewriteExpr(expr Expr, f func(Node) Node) Expr {
+	if expr == nil {
+		return nil
+	}
+	return Rewrite(expr, f).(Expr)
+}
@@ -0,0 +1,115 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/format"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func mustParse(t *testing.T, source string) []ast.Stmt {
+	t.Helper()
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+func TestInspect(t *testing.T) {
+	stmts := mustParse(t, "print 1 + f(2); fun f(a) { return a; }")
+	var visited []string
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		if n == nil {
+			visited = append(visited, "end")
+			return true
+		}
+		visited = append(visited, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		_, isFunction := n.(*ast.Function)
+		return !isFunction
+	})
+	want := []string{
+		"Print", "Binary", "Literal", "end", "Call", "Variable", "end", "Literal", "end", "end", "end", "end",
+		"Function",
+	}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+}
+
+// dropPrints removes every print statement.
+func dropPrints(n ast.Node) ast.Node {
+	if _, ok := n.(*ast.Print); ok {
+		return nil
+	}
+	return n
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		f      func(ast.Node) ast.Node
+		want   string
+	}{
+		{"identity", "var a = 1; print a + 2;", func(n ast.Node) ast.Node { return n }, "var a = 1;\nprint a + 2;\n"},
+		{"expressions", "print 1 + 2 * 3;", func(n ast.Node) ast.Node {
+			if lit, ok := n.(*ast.Literal); ok {
+				return &ast.Literal{Value: lit.Value.(float64) * 10}
+			}
+			return n
+		}, "print 10 + 20 * 30;\n"},
+		{"remove from list", "var a = 1; print a; { print a; a = 2; }", dropPrints, "var a = 1;\n{\n    a = 2;\n}\n"},
+		{"if body", "if (true) print 1; else print 2;", dropPrints, "if (true) {}\n"},
+		{"if without else", "if (true) print 1;", dropPrints, "if (true) {}\n"},
+		{"while body", "while (false) print 1;", dropPrints, "while (false) {}\n"},
+		{"for body", `for (c in "ab") print c;`, dropPrints, "for (c in \"ab\") {}\n"},
+		{"function body", "fun f() { print 1; }", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.Block); ok {
+				return nil
+			}
+			return n
+		}, "fun f() {}\n"},
+		{"lambda body", "var f = () => { print 1; };", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.Block); ok {
+				return nil
+			}
+			return n
+		}, "var f = () => {};\n"},
+		{"arrow expression body", "var f = () => 1 + 2;", func(n ast.Node) ast.Node {
+			if lit, ok := n.(*ast.Literal); ok {
+				return &ast.Literal{Value: lit.Value.(float64) * 10}
+			}
+			return n
+		}, "var f = () => 10 + 20;\n"},
+		{"arrow expression body removed", "var f = () => 1;", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.Return); ok {
+				return nil
+			}
+			return n
+		}, "var f = () => {};\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts := mustParse(t, tt.source)
+			before := internal.PrintStmts(stmts)
+			rewritten := ast.Rewrite(stmts, tt.f).([]ast.Stmt)
+			if got := format.Format(rewritten, nil); got != tt.want {
+				t.Errorf("rewritten program formats as %q, want %q", got, tt.want)
+			}
+			if after := internal.PrintStmts(stmts); after != before {
+				t.Errorf("Rewrite changed the original tree from %v to %v", before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// package cover records which statements and branches of Lox programs run, and reports the coverage as a text summary,
+// an annotated HTML listing or LCOV tracefile.
+package cover
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// Coverage is an engine.BranchHook and engine.ModuleHook that counts statement executions and branch outcomes in the
+// programs it runs and the modules they import.
+type Coverage struct {
+	files  []*file
+	byPath map[string]*file
+	counts map[ast.Stmt]int
+	taken  map[ast.Node]*[2]int // times each branch node ran, then did not run, its conditional part
+}
+
+// file is every statement and branch node parsed from one path; a file loaded twice keeps both parses.
+type file struct {
+	path     string
+	stmts    []ast.Stmt
+	branches []ast.Node
+}
+
+func New() *Coverage {
+	return &Coverage{byPath: make(map[string]*file), counts: make(map[ast.Stmt]int), taken: make(map[ast.Node]*[2]int)}
+}
+
+// Run interprets the statements of the script at path, recording its coverage.
+func (c *Coverage) Run(path string, stmts []ast.Stmt, opts ...engine.Option) (any, error) {
+	c.LoadModule(path, stmts)
+	return engine.NewInterpreter(append(opts, engine.WithPath(path), engine.WithHook(c))...).Interpret(stmts)
+}
+
+// LoadModule implements engine.ModuleHook.
+func (c *Coverage) LoadModule(path string, stmts []ast.Stmt) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	f, ok := c.byPath[path]
+	if !ok {
+		f = &file{path: path}
+		c.byPath[path] = f
+		c.files = append(c.files, f)
+	}
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.If, *ast.While, *ast.For, *ast.Logical:
+			f.branches = append(f.branches, n)
+		}
+		if _, ok := n.(*ast.Block); !ok {
+			if stmt, ok := n.(ast.Stmt); ok {
+				f.stmts = append(f.stmts, stmt)
+			}
+		}
+		return true
+	})
+}
+
+// BeforeStmt implements engine.Hook.
+func (c *Coverage) BeforeStmt(stmt ast.Stmt, depth int) error {
+	c.counts[stmt]++
+	return nil
+}
+
+// Branch implements engine.BranchHook.
+func (c *Coverage) Branch(node ast.Node, taken bool) {
+	t, ok := c.taken[node]
+	if !ok {
+		t = &[2]int{}
+		c.taken[node] = t
+	}
+	if taken {
+		t[0]++
+	} else {
+		t[1]++
+	}
+}
+
+// File is the coverage of one source file.
+type File struct {
+	Path     string
+	Lines    []Line // lines where statements start, in order
+	Branches []Branch
+}
+
+// Line is a line where one or more statements start, with the most times any of them ran.
+type Line struct {
+	Line  int
+	Count int
+}
+
+// Branch is the outcomes of one conditional: an if statement, while or for loop, or and/or operator.
+type Branch struct {
+	Line     int
+	Kind     string
+	Taken    int // times the conditional part ran
+	NotTaken int // times it was skipped
+}
+
+// Files returns the coverage of every file, in the order they were loaded.
+func (c *Coverage) Files() []File {
+	var files []File
+	for _, f := range c.files {
+		counts := make(map[int]int)
+		for _, stmt := range f.stmts {
+			line := ast.StmtLine(stmt)
+			counts[line] = max(counts[line], c.counts[stmt])
+		}
+		rslt := File{Path: f.path}
+		for line, count := range counts {
+			rslt.Lines = append(rslt.Lines, Line{Line: line, Count: count})
+		}
+		sort.Slice(rslt.Lines, func(i, j int) bool { return rslt.Lines[i].Line < rslt.Lines[j].Line })
+
+		for _, node := range f.branches {
+			b := branch(node)
+			if t, ok := c.taken[node]; ok {
+				b.Taken, b.NotTaken = t[0], t[1]
+			}
+			rslt.Branches = append(rslt.Branches, b)
+		}
+		sort.SliceStable(rslt.Branches, func(i, j int) bool { return rslt.Branches[i].Line < rslt.Branches[j].Line })
+		files = append(files, rslt)
+	}
+	return files
+}
+
+func branch(node ast.Node) Branch {
+	switch n := node.(type) {
+	case *ast.If:
+		return Branch{Line: n.Keyword.Line, Kind: "if"}
+	case *ast.While:
+		return Branch{Line: n.Keyword.Line, Kind: "while"}
+	case *ast.For:
+		return Branch{Line: n.Keyword.Line, Kind: "for"}
+	case *ast.Logical:
+		kind := "and"
+		if n.Operator.Type == token.OR {
+			kind = "or"
+		}
+		return Branch{Line: n.Operator.Line, Kind: kind}
+	}
+	panic(fmt.Sprintf("cover: unexpected branch node %T", node))
+}
+
+// Statements returns how many statement lines ran at least once, and how many there are.
+func (f File) Statements() (covered, total int) {
+	for _, l := range f.Lines {
+		if l.Count > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Lines)
+}
+
+// Outcomes returns how many branch outcomes happened at least once, and how many there are: two per branch.
+func (f File) Outcomes() (covered, total int) {
+	for _, b := range f.Branches {
+		if b.Taken > 0 {
+			covered++
+		}
+		if b.NotTaken > 0 {
+			covered++
+		}
+	}
+	return covered, 2 * len(f.Branches)
+}
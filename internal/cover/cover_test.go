@@ -0,0 +1,207 @@
+package cover_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/cover"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+const mainSource = `fun f(n) {
+  if (n > 1) {
+    print "big";
+  } else {
+    print "small";
+  }
+}
+var i = 0;
+while (i < 2) {
+  f(i);
+  i = i + 1;
+}
+for (x in range(3)) print x;
+print false and f(5);
+print true or f(5);
+import "lib.lox" as lib;
+lib.g();
+`
+
+const libSource = `fun g() {
+  print "g";
+}
+fun h() {
+  print "h";
+}
+`
+
+// run records the coverage of running the script source, as main.lox in a directory of its own along with the
+// modules, and returns the coverage and the directory.
+func run(t *testing.T, source string, modules map[string]string) ([]cover.File, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules["main.lox"] = source
+	for name, contents := range modules {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cover.New()
+	_, err = c.Run(filepath.Join(dir, "main.lox"), stmts,
+		engine.WithLoader(engine.NewLoader(scan.Scan, parse.Parse, nil)),
+		engine.AllowFS(dir),
+		engine.WithStdout(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Files(), dir
+}
+
+func TestFiles(t *testing.T) {
+	files, dir := run(t, mainSource, map[string]string{"lib.lox": libSource})
+	want := []cover.File{
+		{
+			Path: filepath.Join(dir, "main.lox"),
+			Lines: []cover.Line{
+				{Line: 1, Count: 1}, {Line: 2, Count: 2}, {Line: 3, Count: 0}, {Line: 5, Count: 2},
+				{Line: 8, Count: 1}, {Line: 9, Count: 1}, {Line: 10, Count: 2}, {Line: 11, Count: 2},
+				{Line: 13, Count: 3}, {Line: 14, Count: 1}, {Line: 15, Count: 1}, {Line: 16, Count: 1},
+				{Line: 17, Count: 1},
+			},
+			Branches: []cover.Branch{
+				{Line: 2, Kind: "if", Taken: 0, NotTaken: 2},
+				{Line: 9, Kind: "while", Taken: 2, NotTaken: 1},
+				{Line: 13, Kind: "for", Taken: 3, NotTaken: 1},
+				{Line: 14, Kind: "and", Taken: 0, NotTaken: 1},
+				{Line: 15, Kind: "or", Taken: 0, NotTaken: 1},
+			},
+		},
+		{
+			Path:  filepath.Join(dir, "lib.lox"),
+			Lines: []cover.Line{{Line: 1, Count: 1}, {Line: 2, Count: 1}, {Line: 4, Count: 1}, {Line: 5, Count: 0}},
+		},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Files() = %+v, want %+v", files, want)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	files, dir := run(t, mainSource, map[string]string{"lib.lox": libSource})
+	var b bytes.Buffer
+	if err := cover.WriteText(&b, files); err != nil {
+		t.Fatal(err)
+	}
+	want := `DIR/main.lox: 92.3% (12/13) of statements, 70.0% (7/10) of branches; not run: 3
+DIR/lib.lox: 75.0% (3/4) of statements, 100.0% (0/0) of branches; not run: 5
+total: 88.2% (15/17) of statements, 70.0% (7/10) of branches
+`
+	if got := strings.ReplaceAll(b.String(), dir, "DIR"); got != want {
+		t.Errorf("WriteText() wrote\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	files, dir := run(t, mainSource, map[string]string{"lib.lox": libSource})
+	var b bytes.Buffer
+	if err := cover.WriteLCOV(&b, files); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:DIR/main.lox
+BRDA:2,0,0,0
+BRDA:2,0,1,2
+BRDA:9,1,0,2
+BRDA:9,1,1,1
+BRDA:13,2,0,3
+BRDA:13,2,1,1
+BRDA:14,3,0,0
+BRDA:14,3,1,1
+BRDA:15,4,0,0
+BRDA:15,4,1,1
+BRF:10
+BRH:7
+DA:1,1
+DA:2,2
+DA:3,0
+DA:5,2
+DA:8,1
+DA:9,1
+DA:10,2
+DA:11,2
+DA:13,3
+DA:14,1
+DA:15,1
+DA:16,1
+DA:17,1
+LF:13
+LH:12
+end_of_record
+TN:
+SF:DIR/lib.lox
+BRF:0
+BRH:0
+DA:1,1
+DA:2,1
+DA:4,1
+DA:5,0
+LF:4
+LH:3
+end_of_record
+`
+	if got := strings.ReplaceAll(b.String(), dir, "DIR"); got != want {
+		t.Errorf("WriteLCOV() wrote\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	source := `var a = 1;
+if (a < 2) print "<a>";
+if (a > 2) {
+  print a;
+}
+`
+	files, _ := run(t, source, map[string]string{})
+	var b bytes.Buffer
+	if err := cover.WriteHTML(&b, files); err != nil {
+		t.Fatal(err)
+	}
+	head, body, ok := strings.Cut(b.String(), "<body>\n")
+	if !strings.HasPrefix(head, "<!DOCTYPE html>\n") || !ok {
+		t.Fatalf("WriteHTML() wrote %q, want an HTML page", b.String())
+	}
+	want := `<h2>main.lox</h2>
+<p>75.0% (3/4) of statements, 50.0% (2/4) of branches</p>
+<table>
+<tr class="covered"><td class="line">1</td><td class="count">1</td><td class="source">var a = 1;</td></tr>
+<tr class="partial"><td class="line">2</td><td class="count">1</td><td class="source">if (a &lt; 2) print &#34;&lt;a&gt;&#34;;</td></tr>
+<tr class="partial"><td class="line">3</td><td class="count">1</td><td class="source">if (a &gt; 2) {</td></tr>
+<tr class="missed"><td class="line">4</td><td class="count">0</td><td class="source">  print a;</td></tr>
+<tr class=""><td class="line">5</td><td class="count"></td><td class="source">}</td></tr>
+</table>
+</body>
+</html>
+`
+	if body != want {
+		t.Errorf("WriteHTML() wrote\n%v\nwant\n%v", body, want)
+	}
+}
@@ -0,0 +1,149 @@
+package cover
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes one summary line per file, naming the statement lines that never ran, followed by the totals.
+func WriteText(w io.Writer, files []File) error {
+	var covered, total, bcovered, btotal int
+	for _, f := range files {
+		c, t := f.Statements()
+		bc, bt := f.Outcomes()
+		covered, total, bcovered, btotal = covered+c, total+t, bcovered+bc, btotal+bt
+
+		var missed []int
+		for _, l := range f.Lines {
+			if l.Count == 0 {
+				missed = append(missed, l.Line)
+			}
+		}
+		fmt.Fprintf(w, "%v: %v of statements, %v of branches", f.Path, percent(c, t), percent(bc, bt))
+		if len(missed) > 0 {
+			fmt.Fprintf(w, "; not run: %v", ranges(missed))
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintf(w, "total: %v of statements, %v of branches\n", percent(covered, total), percent(bcovered, btotal))
+	return err
+}
+
+func percent(covered, total int) string {
+	if total == 0 {
+		return "100.0% (0/0)"
+	}
+	return fmt.Sprintf("%.1f%% (%v/%v)", 100*float64(covered)/float64(total), covered, total)
+}
+
+// ranges formats sorted line numbers compactly, as in "3, 5-7".
+func ranges(lines []int) string {
+	var parts []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(lines[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%v-%v", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, with one record per file.
+func WriteLCOV(w io.Writer, files []File) error {
+	for _, f := range files {
+		fmt.Fprintf(w, "TN:\nSF:%v\n", f.Path)
+		for i, b := range f.Branches {
+			if b.Taken == 0 && b.NotTaken == 0 {
+				fmt.Fprintf(w, "BRDA:%v,%v,0,-\nBRDA:%v,%v,1,-\n", b.Line, i, b.Line, i)
+			} else {
+				fmt.Fprintf(w, "BRDA:%v,%v,0,%v\nBRDA:%v,%v,1,%v\n", b.Line, i, b.Taken, b.Line, i, b.NotTaken)
+			}
+		}
+		bc, bt := f.Outcomes()
+		fmt.Fprintf(w, "BRF:%v\nBRH:%v\n", bt, bc)
+		for _, l := range f.Lines {
+			fmt.Fprintf(w, "DA:%v,%v\n", l.Line, l.Count)
+		}
+		c, t := f.Statements()
+		if _, err := fmt.Fprintf(w, "LF:%v\nLH:%v\nend_of_record\n", t, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lox coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; white-space: pre; }
+td { padding: 0 0.5em; }
+td.line, td.count { color: #888; text-align: right; }
+tr.covered td.source { background: #dfd; }
+tr.partial td.source { background: #ffc; }
+tr.missed td.source { background: #fdd; }
+</style>
+</head>
+<body>
+`
+
+// WriteHTML writes an HTML page listing the source of each file, with every line colored by its coverage: green for
+// lines that ran, red for lines that did not, and yellow for lines that ran but have a branch outcome that never
+// happened. Each file is read again from its path.
+func WriteHTML(w io.Writer, files []File) error {
+	fmt.Fprint(w, htmlHead)
+	for _, f := range files {
+		bytes, err := os.ReadFile(f.Path)
+		if err != nil {
+			return err
+		}
+
+		counts := make(map[int]int)
+		for _, l := range f.Lines {
+			counts[l.Line] = l.Count
+		}
+		partial := make(map[int]bool)
+		for _, b := range f.Branches {
+			partial[b.Line] = partial[b.Line] || b.Taken == 0 || b.NotTaken == 0
+		}
+
+		c, t := f.Statements()
+		bc, bt := f.Outcomes()
+		fmt.Fprintf(w, "<h2>%v</h2>\n<p>%v of statements, %v of branches</p>\n<table>\n",
+			html.EscapeString(filepath.Base(f.Path)), percent(c, t), percent(bc, bt))
+		for i, text := range strings.Split(strings.TrimSuffix(string(bytes), "\n"), "\n") {
+			line := i + 1
+			class, count := "", ""
+			if n, ok := counts[line]; ok {
+				count = strconv.Itoa(n)
+				switch {
+				case n == 0:
+					class = "missed"
+				case partial[line]:
+					class = "partial"
+				default:
+					class = "covered"
+				}
+			}
+			fmt.Fprintf(w, "<tr class=\"%v\"><td class=\"line\">%v</td><td class=\"count\">%v</td><td class=\"source\">%v</td></tr>\n",
+				class, line, count, html.EscapeString(strings.TrimRight(text, "\r")))
+		}
+		fmt.Fprint(w, "</table>\n")
+	}
+	_, err := fmt.Fprint(w, "</body>\n</html>\n")
+	return err
+}
@@ -0,0 +1,434 @@
+// package dap implements a Debug Adapter Protocol server for Lox over a pair of streams, usually stdin and stdout, so
+// that editors can set breakpoints in and step through the statements of a script.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/debug"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/format"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/rpc"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// Tasks run without hooks, out of the debugger's sight, so the program is the only thread it shows. Stack frames are
+// numbered from 1, the innermost.
+const threadID = 1
+
+var errNotPaused = errors.New("the program is not paused")
+
+// Server debugs one program per session. It implements debug.Frontend: the program runs on its own goroutine, which
+// blocks in Pause while the client inspects it.
+type Server struct {
+	conn    *rpc.Conn
+	writeMu sync.Mutex
+	seq     int
+
+	loader      *engine.Loader
+	breakpoints map[string][]int // requested breakpoint lines by absolute source path
+	program     string           // absolute path of the launched script
+	stmts       []ast.Stmt
+	noDebug     bool
+	debugger    *debug.Debugger
+	configured  bool
+	started     bool
+	done        chan struct{}
+
+	mu     sync.Mutex
+	paused bool
+	quit   bool
+	resume chan error
+}
+
+// NewServer returns a server whose programs import modules through loader, which may be nil to disable imports.
+func NewServer(r io.Reader, w io.Writer, loader *engine.Loader) *Server {
+	return &Server{
+		conn:        rpc.NewConn(r, w),
+		loader:      loader,
+		breakpoints: make(map[string][]int),
+		done:        make(chan struct{}),
+		resume:      make(chan error),
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the stream, then stops the program.
+func (s *Server) Serve() error {
+	defer s.stop()
+	for {
+		msg, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			return fmt.Errorf("malformed message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(req)
+		if err := s.reply(req, body, err); err != nil {
+			return err
+		}
+
+		// anything that lets the program run waits for the response, so that it precedes the program's events
+		switch req.Command {
+		case "initialize":
+			if err := s.send("initialized", nil); err != nil {
+				return err
+			}
+		case "launch", "configurationDone":
+			s.start()
+		case "continue", "next", "stepIn", "stepOut":
+			if err == nil {
+				s.resume <- nil
+			}
+		case "disconnect":
+			return nil
+		case "terminate":
+			s.stop()
+		}
+	}
+}
+
+func (s *Server) reply(req request, body any, err error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	resp := response{Seq: s.seq, Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return s.conn.Write(resp)
+}
+
+func (s *Server) send(name string, body any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	return s.conn.Write(event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(req.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+	case "threads":
+		return ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		return ContinueResponseBody{AllThreadsContinued: true}, s.step((*debug.Debugger).Continue)
+	case "next":
+		return nil, s.step((*debug.Debugger).StepOver)
+	case "stepIn":
+		return nil, s.step((*debug.Debugger).StepInto)
+	case "stepOut":
+		return nil, s.step((*debug.Debugger).StepOut)
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.Interrupt()
+		}
+		return nil, nil
+	case "disconnect", "terminate":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+func (s *Server) launch(raw json.RawMessage) error {
+	if s.debugger != nil {
+		return errors.New("a program is already launched")
+	}
+	var args LaunchArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return errors.New("launch requires a program")
+	}
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	stmts, err := parseFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.program, s.stmts, s.noDebug = path, stmts, args.NoDebug
+	s.debugger = debug.New(s, args.StopOnEntry && !args.NoDebug)
+	return nil
+}
+
+func parseFile(path string) ([]ast.Stmt, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := scan.Scan(string(bytes))
+	if err != nil {
+		return nil, err
+	}
+	return parse.Parse(tokens)
+}
+
+// setBreakpoints replaces the breakpoints of a source file. Only lines where a statement starts are verified, and only
+// breakpoints in the launched program are ever hit; imported modules run without the debugger.
+func (s *Server) setBreakpoints(raw json.RawMessage) (any, error) {
+	var args SetBreakpointsArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines map[int]bool
+	var message string
+	if stmts, err := parseFile(path); err != nil {
+		message = err.Error()
+	} else {
+		lines = debug.Lines(stmts)
+		message = "no statement starts on this line"
+	}
+
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	var verified []int
+	for _, bp := range args.Breakpoints {
+		if lines[bp.Line] {
+			verified = append(verified, bp.Line)
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: true, Line: bp.Line})
+		} else {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Line: bp.Line, Message: message})
+		}
+	}
+	s.breakpoints[path] = verified
+	if s.debugger != nil && path == s.program && !s.noDebug {
+		s.debugger.SetBreakpoints(verified)
+	}
+	return body, nil
+}
+
+// start runs the program once it is launched and the client has finished configuring breakpoints.
+func (s *Server) start() {
+	if s.debugger == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	if !s.noDebug {
+		s.debugger.SetBreakpoints(s.breakpoints[s.program])
+	}
+
+	go func() {
+		defer close(s.done)
+		// the program has all the capabilities of a script run from the command line, but standard input carries the
+		// protocol, so it reads an empty one
+		opts := []engine.Option{
+			engine.WithPath(s.program),
+			engine.WithStdout(output{s, "stdout"}),
+			engine.AllowAll(),
+			engine.WithStdin(strings.NewReader("")),
+		}
+		if s.loader != nil {
+			opts = append(opts, engine.WithLoader(s.loader))
+		}
+		_, err := s.debugger.Run(s.stmts, opts...)
+		code := 0
+		if err != nil && !errors.Is(err, debug.ErrQuit) {
+			s.send("output", OutputEventBody{Category: "stderr", Output: err.Error() + "\n"})
+			code = 1
+		}
+		s.send("exited", ExitedEventBody{ExitCode: code})
+		s.send("terminated", nil)
+	}()
+}
+
+// stop ends the program, if it is running, and waits for it to finish.
+func (s *Server) stop() {
+	s.mu.Lock()
+	paused := s.paused
+	s.quit, s.paused = true, false
+	s.mu.Unlock()
+
+	if !s.started {
+		return
+	}
+	if paused {
+		s.resume <- debug.ErrQuit
+	} else {
+		s.debugger.Interrupt()
+	}
+	<-s.done
+}
+
+// Pause implements debug.Frontend.
+func (s *Server) Pause(d *debug.Debugger, reason string) error {
+	s.mu.Lock()
+	if s.quit {
+		s.mu.Unlock()
+		return debug.ErrQuit
+	}
+	s.paused = true
+	s.mu.Unlock()
+
+	if err := s.send("stopped", StoppedEventBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true}); err != nil {
+		return err
+	}
+	return <-s.resume
+}
+
+func (s *Server) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// step prepares the debugger to resume with resume; Serve lets the program go once the response is sent.
+func (s *Server) step(resume func(*debug.Debugger)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return errNotPaused
+	}
+	s.paused = false
+	resume(s.debugger)
+	return nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	if !s.isPaused() {
+		return nil, errNotPaused
+	}
+	body := StackTraceResponseBody{StackFrames: []StackFrame{}}
+	for n, frame := range s.debugger.Frames() {
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     n + 1,
+			Name:   frame.Name,
+			Source: &Source{Name: filepath.Base(s.program), Path: s.program},
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+	body.TotalFrames = len(body.StackFrames)
+	return body, nil
+}
+
+// scopes lists the scopes of the current statement, innermost first. A scope's variables reference is its position
+// in that list, plus one. The scopes of the callers are not kept, so outer frames show only the globals.
+func (s *Server) scopes(raw json.RawMessage) (any, error) {
+	var args ScopesArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if !s.isPaused() {
+		return nil, errNotPaused
+	}
+	if args.FrameID < 1 || args.FrameID > len(s.debugger.Frames()) {
+		return nil, fmt.Errorf("unknown frame %v", args.FrameID)
+	}
+	scopes := s.debugger.Scopes()
+	body := ScopesResponseBody{Scopes: []Scope{}}
+	for i := range scopes {
+		if args.FrameID > 1 && i < len(scopes)-1 {
+			continue
+		}
+		scope := Scope{Name: "Block", VariablesReference: i + 1}
+		switch {
+		case i == len(scopes)-1:
+			scope.Name = "Globals"
+		case i == 0:
+			scope.Name, scope.PresentationHint = "Locals", "locals"
+		}
+		body.Scopes = append(body.Scopes, scope)
+	}
+	return body, nil
+}
+
+func (s *Server) variables(raw json.RawMessage) (any, error) {
+	var args VariablesArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if !s.isPaused() {
+		return nil, errNotPaused
+	}
+	scopes := s.debugger.Scopes()
+	if args.VariablesReference < 1 || args.VariablesReference > len(scopes) {
+		return nil, fmt.Errorf("unknown variables reference %v", args.VariablesReference)
+	}
+
+	scope := scopes[args.VariablesReference-1]
+	names := make([]string, 0, len(scope))
+	for name := range scope {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	body := VariablesResponseBody{Variables: []Variable{}}
+	for _, name := range names {
+		body.Variables = append(body.Variables, Variable{Name: name, Value: format.Literal(scope[name])})
+	}
+	return body, nil
+}
+
+func (s *Server) evaluate(raw json.RawMessage) (any, error) {
+	var args EvaluateArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if !s.isPaused() {
+		return nil, errNotPaused
+	}
+	rslt, err := s.debugger.Evaluate(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateResponseBody{Result: format.Literal(rslt)}, nil
+}
+
+// output forwards what the program prints to the client as output events.
+type output struct {
+	s        *Server
+	category string
+}
+
+func (o output) Write(p []byte) (int, error) {
+	if err := o.s.send("output", OutputEventBody{Category: o.category, Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,295 @@
+package dap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/rpc"
+)
+
+// client drives a Server over a pipe, keeping the events it reads while waiting for responses.
+type client struct {
+	t      *testing.T
+	conn   *rpc.Conn
+	seq    int
+	events []event
+	msgs   chan json.RawMessage
+}
+
+type message struct {
+	response
+	Event string          `json:"event"`
+	Body  json.RawMessage `json:"body"`
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(serverIn, serverOut, nil).Serve()
+		serverOut.Close()
+	}()
+
+	c := &client{t: t, conn: rpc.NewConn(clientIn, clientOut), msgs: make(chan json.RawMessage, 100)}
+	go func() {
+		defer close(c.msgs)
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		for range c.msgs {
+		}
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return c
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	raw, ok := <-c.msgs
+	if !ok {
+		c.t.Fatal("server closed the stream")
+	}
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// request sends a request and returns the body of its response, failing the test if it was unsuccessful.
+func (c *client) request(command string, args any, body any) {
+	c.t.Helper()
+	c.seq++
+	raw, _ := json.Marshal(args)
+	if err := c.conn.Write(request{Seq: c.seq, Type: "request", Command: command, Arguments: raw}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, event{Event: msg.Event, Body: msg.Body})
+			continue
+		}
+		if msg.RequestSeq != c.seq || msg.Command != command {
+			c.t.Fatalf("response to %v %v, want %v %v", msg.Command, msg.RequestSeq, command, c.seq)
+		}
+		if !msg.Success {
+			c.t.Fatalf("%v failed: %v", command, msg.Message)
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+// await returns the body of the next event with the given name, including one already read.
+func (c *client) await(name string) json.RawMessage {
+	c.t.Helper()
+	for i, e := range c.events {
+		if e.Event == name {
+			c.events = append(c.events[:i], c.events[i+1:]...)
+			body, _ := e.Body.(json.RawMessage)
+			return body
+		}
+	}
+	for {
+		msg := c.next()
+		if msg.Type == "event" && msg.Event == name {
+			return msg.Body
+		}
+		if msg.Type == "event" {
+			c.events = append(c.events, event{Event: msg.Event, Body: msg.Body})
+		}
+	}
+}
+
+// stopped waits for the program to stop for reason, and returns its stack frames once checking that it stopped at
+// line.
+func (c *client) stopped(reason string, line int) []StackFrame {
+	c.t.Helper()
+	var body StoppedEventBody
+	json.Unmarshal(c.await("stopped"), &body)
+	if body.Reason != reason {
+		c.t.Errorf("stopped for %q, want %q", body.Reason, reason)
+	}
+	var trace StackTraceResponseBody
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) == 0 || trace.StackFrames[0].Line != line {
+		c.t.Fatalf("stopped at %+v, want line %v", trace.StackFrames, line)
+	}
+	return trace.StackFrames
+}
+
+func (c *client) output() string {
+	var b strings.Builder
+	for _, e := range c.events {
+		if e.Event == "output" {
+			var body OutputEventBody
+			json.Unmarshal(e.Body.(json.RawMessage), &body)
+			b.WriteString(body.Output)
+		}
+	}
+	return b.String()
+}
+
+const script = `var a = 1;
+{
+    var b = a + 1;
+    print b;
+    print a + b;
+}
+print "done";
+`
+
+func TestDebugSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+
+	var caps Capabilities
+	c.request("initialize", map[string]string{"adapterID": "lox"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Error("configurationDone not supported")
+	}
+	c.await("initialized")
+	c.request("launch", LaunchArguments{Program: path}, nil)
+
+	var bps SetBreakpointsResponseBody
+	args := SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: []SourceBreakpoint{{Line: 2}, {Line: 4}}}
+	c.request("setBreakpoints", args, &bps)
+	if len(bps.Breakpoints) != 2 || bps.Breakpoints[0].Verified || !bps.Breakpoints[1].Verified {
+		t.Errorf("breakpoints = %+v, want line 2 unverified and line 4 verified", bps.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+	c.stopped("breakpoint", 4)
+
+	var scopes ScopesResponseBody
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v, want Locals and Globals", scopes.Scopes)
+	}
+	for i, want := range []string{"b = 2", "a = 1"} {
+		var vars VariablesResponseBody
+		c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[i].VariablesReference}, &vars)
+		if len(vars.Variables) != 1 || vars.Variables[0].Name+" = "+vars.Variables[0].Value != want {
+			t.Errorf("%v variables = %+v, want %v", scopes.Scopes[i].Name, vars.Variables, want)
+		}
+	}
+
+	var eval EvaluateResponseBody
+	c.request("evaluate", EvaluateArguments{Expression: "a + b * 10", FrameID: 1}, &eval)
+	if eval.Result != "21" {
+		t.Errorf("evaluate = %v, want 21", eval.Result)
+	}
+
+	c.request("next", map[string]int{"threadId": threadID}, nil)
+	c.stopped("step", 5)
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.stopped("step", 7)
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+
+	var exited ExitedEventBody
+	json.Unmarshal(c.await("exited"), &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code %v, want 0", exited.ExitCode)
+	}
+	c.await("terminated")
+	if got, want := c.output(), "2\n3\ndone\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	c.request("disconnect", nil, nil)
+}
+
+func TestDisconnectWhilePaused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.lox")
+	if err := os.WriteFile(path, []byte("while (true) {\n    print 1;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+
+	c.request("initialize", nil, nil)
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.request("configurationDone", nil, nil)
+	c.stopped("entry", 1)
+	c.request("disconnect", nil, nil)
+}
+
+const calls = `fun twice(f, x) {
+    return f(f(x));
+}
+fun inc(x) {
+    return x + 1;
+}
+print twice(inc, 1);
+print twice((x) => x * 2, 3);
+`
+
+func TestStackFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.lox")
+	if err := os.WriteFile(path, []byte(calls), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", LaunchArguments{Program: path}, nil)
+	args := SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: []SourceBreakpoint{{Line: 5}, {Line: 8}}}
+	c.request("setBreakpoints", args, nil)
+	c.request("configurationDone", nil, nil)
+
+	frames := c.stopped("breakpoint", 5)
+	var got []string
+	for _, f := range frames {
+		got = append(got, fmt.Sprintf("%v:%v", f.Name, f.Line))
+	}
+	if want := []string{"inc:5", "twice:2", "main:7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stack = %v, want %v", got, want)
+	}
+
+	var scopes ScopesResponseBody
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	// the parameters of a function are in the scope around its body
+	var vars VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &vars)
+	if len(vars.Variables) != 1 || vars.Variables[0].Name != "x" || vars.Variables[0].Value != "1" {
+		t.Errorf("parameters of inc = %+v, want x = 1", vars.Variables)
+	}
+	c.request("scopes", ScopesArguments{FrameID: 3}, &scopes)
+	if len(scopes.Scopes) != 1 || scopes.Scopes[0].Name != "Globals" {
+		t.Errorf("scopes of main = %+v, want the globals", scopes.Scopes)
+	}
+
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	c.stopped("breakpoint", 5)
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	if frames := c.stopped("breakpoint", 8); len(frames) != 1 {
+		t.Errorf("stack = %+v, want main alone", frames)
+	}
+	// the body of the lambda is on the same line
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	frames = c.stopped("breakpoint", 8)
+	if len(frames) != 3 || frames[0].Name != "lambda" || frames[1].Name != "twice" || frames[2].Name != "main" {
+		t.Errorf("stack in lambda = %+v, want lambda, twice and main", frames)
+	}
+	c.request("disconnect", nil, nil)
+}
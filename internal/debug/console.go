@@ -0,0 +1,157 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/format"
+)
+
+const consoleHelp = `Commands:
+  s, step          stop at the next statement
+  n, next          stop at the next statement not nested in this one
+  o, out           stop at the next statement outside the enclosing block or loop
+  c, continue      run until the next breakpoint
+  b, break LINE    set a breakpoint
+  d, delete LINE   remove a breakpoint
+  breakpoints      list breakpoints
+  p, print EXPR    evaluate an expression in the current scope
+  scopes           show the variables in each enclosing scope
+  bt, where        show the statements executing around this one
+  l, list          show the source around the current line
+  q, quit          stop the program
+  h, help          show this help
+`
+
+// Console is a line-oriented Frontend that reads commands from a reader and writes to a writer.
+type Console struct {
+	path  string
+	lines []string
+	in    *bufio.Scanner
+	out   io.Writer
+}
+
+// NewConsole returns a console debugging the script at path, whose text is source.
+func NewConsole(path, source string, in io.Reader, out io.Writer) *Console {
+	return &Console{path: path, lines: strings.Split(source, "\n"), in: bufio.NewScanner(in), out: out}
+}
+
+// Pause implements Frontend.
+func (c *Console) Pause(d *Debugger, reason string) error {
+	if reason == "breakpoint" {
+		fmt.Fprintf(c.out, "breakpoint at ")
+	}
+	c.location(d.Line())
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return ErrQuit
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(c.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case "":
+		case "s", "step":
+			d.StepInto()
+			return nil
+		case "n", "next":
+			d.StepOver()
+			return nil
+		case "o", "out":
+			d.StepOut()
+			return nil
+		case "c", "continue":
+			d.Continue()
+			return nil
+		case "b", "break":
+			if line, ok := c.lineArg(arg); ok {
+				d.SetBreakpoint(line)
+				fmt.Fprintf(c.out, "breakpoint set at line %v\n", line)
+			}
+		case "d", "delete":
+			if line, ok := c.lineArg(arg); ok {
+				d.ClearBreakpoint(line)
+			}
+		case "breakpoints":
+			for _, line := range d.Breakpoints() {
+				c.location(line)
+			}
+		case "p", "print":
+			rslt, err := d.Evaluate(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+			} else {
+				fmt.Fprintln(c.out, format.Literal(rslt))
+			}
+		case "scopes":
+			c.scopes(d.Scopes())
+		case "bt", "where":
+			for _, stmt := range d.Stack() {
+				c.location(ast.StmtLine(stmt))
+			}
+		case "l", "list":
+			c.list(d.Line())
+		case "q", "quit":
+			return ErrQuit
+		case "h", "help":
+			fmt.Fprint(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q; type help for a list\n", command)
+		}
+	}
+}
+
+func (c *Console) lineArg(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "expected a line number, got %q\n", arg)
+		return 0, false
+	}
+	return line, true
+}
+
+func (c *Console) location(line int) {
+	fmt.Fprintf(c.out, "%v:%v: %v\n", c.path, line, strings.TrimSpace(c.source(line)))
+}
+
+func (c *Console) source(line int) string {
+	if line < 1 || line > len(c.lines) {
+		return ""
+	}
+	return strings.TrimRight(c.lines[line-1], "\r")
+}
+
+// list prints the five lines either side of line, marking line itself.
+func (c *Console) list(line int) {
+	for l := max(line-5, 1); l <= min(line+5, len(c.lines)); l++ {
+		marker := " "
+		if l == line {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%v %4d  %v\n", marker, l, c.source(l))
+	}
+}
+
+func (c *Console) scopes(scopes []map[string]any) {
+	for depth, scope := range scopes {
+		name := "block"
+		if depth == len(scopes)-1 {
+			name = "global"
+		}
+		fmt.Fprintf(c.out, "%v scope:\n", name)
+		names := make([]string, 0, len(scope))
+		for name := range scope {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(c.out, "  %v = %v\n", name, format.Literal(scope[name]))
+		}
+	}
+}
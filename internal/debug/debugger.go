@@ -0,0 +1,277 @@
+// package debug implements a statement-level debugger for Lox on top of the interpreter's execution hook.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// ErrQuit is returned by a Frontend to abandon the program being debugged.
+var ErrQuit = errors.New("debugger quit")
+
+// Frontend is the user interface of a Debugger.
+type Frontend interface {
+	// Pause is called, on the interpreter's goroutine, each time execution stops. It may inspect the program through
+	// the Debugger, and returns once the user has chosen how to resume by calling Continue, StepInto, StepOver or
+	// StepOut. Reason is "entry", "breakpoint", "step" or "pause". Returning an error stops the program.
+	Pause(d *Debugger, reason string) error
+}
+
+type mode int
+
+const (
+	running mode = iota
+	entry
+	stepInto
+	stepOver
+	stepOut
+)
+
+// Debugger stops execution at breakpoints and after steps. Each stop is at a statement; blocks themselves are never
+// stopped at, only the statements inside them.
+//
+// Breakpoints may be changed and Interrupt called from any goroutine. Everything else must happen on the
+// interpreter's goroutine, or while it is blocked in Frontend.Pause.
+type Debugger struct {
+	frontend    Frontend
+	interpreter *engine.Interpreter
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	interrupt   atomic.Bool
+	mode        mode
+	depth       int                   // depth of the statement where the current step began
+	stack       []ast.Stmt            // the statement about to run, and the statements executing around it
+	bodies      map[*ast.Block]string // the names of the functions and lambdas whose bodies these are
+}
+
+// Frame is a call of a function executing in the program, or the program itself.
+type Frame struct {
+	Name string // the name of the function, lambda for a lambda, or main for the program
+	Line int    // the line of the statement the frame is executing
+}
+
+// New returns a debugger reporting to frontend. With stopOnEntry, execution stops before the first statement.
+func New(frontend Frontend, stopOnEntry bool) *Debugger {
+	d := &Debugger{frontend: frontend, breakpoints: make(map[int]bool)}
+	if stopOnEntry {
+		d.mode = entry
+	}
+	return d
+}
+
+// Lines returns the lines of stmts on which the debugger can stop: those where a statement other than a block starts.
+func Lines(stmts []ast.Stmt) map[int]bool {
+	lines := make(map[int]bool)
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Stmt); ok {
+			if _, ok := stmt.(*ast.Block); !ok {
+				lines[ast.StmtLine(stmt)] = true
+			}
+		}
+		return true
+	})
+	return lines
+}
+
+// Run interprets stmts under the debugger.
+func (d *Debugger) Run(stmts []ast.Stmt, opts ...engine.Option) (any, error) {
+	d.bodies = make(map[*ast.Block]string)
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Function:
+			d.bodies[n.Body] = n.Name.Lexeme
+		case *ast.Lambda:
+			d.bodies[n.Body] = "lambda"
+		}
+		return true
+	})
+	d.interpreter = engine.NewInterpreter(append(opts, engine.WithHook(d))...)
+	return d.interpreter.Interpret(stmts)
+}
+
+// BeforeStmt implements engine.Hook.
+func (d *Debugger) BeforeStmt(stmt ast.Stmt, depth int) error {
+	d.stack = append(d.stack[:depth], stmt)
+	if _, ok := stmt.(*ast.Block); ok {
+		return nil
+	}
+
+	d.mu.Lock()
+	breakpoint := d.breakpoints[ast.StmtLine(stmt)]
+	d.mu.Unlock()
+
+	var reason string
+	switch {
+	case d.mode == entry:
+		reason = "entry"
+	case breakpoint:
+		reason = "breakpoint"
+	case d.interrupt.Swap(false):
+		reason = "pause"
+	case d.mode == stepInto,
+		d.mode == stepOver && depth <= d.depth,
+		d.mode == stepOut && depth < d.depth:
+		reason = "step"
+	default:
+		return nil
+	}
+	d.mode = running
+	return d.frontend.Pause(d, reason)
+}
+
+// Resume implements engine.ResumeHook.
+func (d *Debugger) Resume(stmts []ast.Stmt, depth int) {
+	d.stack = append(d.stack[:depth], stmts...)
+}
+
+// Continue resumes execution until the next breakpoint.
+func (d *Debugger) Continue() {
+	d.mode = running
+}
+
+// StepInto stops at the next statement, including those nested inside the current one.
+func (d *Debugger) StepInto() {
+	d.mode = stepInto
+}
+
+// StepOver stops at the next statement that is not nested inside the current one.
+func (d *Debugger) StepOver() {
+	d.mode = stepOver
+	d.depth = len(d.stack) - 1
+}
+
+// StepOut stops at the next statement outside the statement enclosing the current one.
+func (d *Debugger) StepOut() {
+	d.mode = stepOut
+	d.depth = len(d.stack) - 1
+}
+
+// Interrupt stops the running program before its next statement.
+func (d *Debugger) Interrupt() {
+	d.interrupt.Store(true)
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// SetBreakpoints replaces all breakpoints with the given lines.
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.breakpoints)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Breakpoints returns the lines with breakpoints, in order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Line returns the line of the statement about to run, or 0 before the program starts.
+func (d *Debugger) Line() int {
+	if len(d.stack) == 0 {
+		return 0
+	}
+	return ast.StmtLine(d.stack[len(d.stack)-1])
+}
+
+// Stack returns the statement about to run followed by the statements executing around it, innermost first. Blocks
+// are left out.
+func (d *Debugger) Stack() []ast.Stmt {
+	var stack []ast.Stmt
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		if _, ok := d.stack[i].(*ast.Block); !ok {
+			stack = append(stack, d.stack[i])
+		}
+	}
+	return stack
+}
+
+// Frames returns the function calls executing, innermost first, followed by the program itself.
+func (d *Debugger) Frames() []Frame {
+	var frames []Frame
+	line := 0
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		block, ok := d.stack[i].(*ast.Block)
+		if !ok {
+			if line == 0 {
+				line = ast.StmtLine(d.stack[i])
+			}
+			continue
+		}
+		if name, ok := d.bodies[block]; ok {
+			frames = append(frames, Frame{Name: name, Line: line})
+			line = 0
+		}
+	}
+	return append(frames, Frame{Name: "main", Line: line})
+}
+
+// Scopes returns the bindings of each scope visible to the current statement, innermost first; the last is the
+// global scope. Native functions are left out, as they are not the program's own.
+func (d *Debugger) Scopes() []map[string]any {
+	var scopes []map[string]any
+	if d.interpreter == nil {
+		return nil
+	}
+	for env := d.interpreter.Running().Environment(); env != nil; env = env.Unwrap() {
+		values := env.Values()
+		for name, value := range values {
+			if _, ok := value.(*engine.Native); ok {
+				delete(values, name)
+			}
+		}
+		scopes = append(scopes, values)
+	}
+	return scopes
+}
+
+// Evaluate evaluates a Lox expression in the scope of the current statement. Assignments in the expression change
+// the program's variables.
+func (d *Debugger) Evaluate(source string) (any, error) {
+	if d.interpreter == nil {
+		return nil, errors.New("program is not running")
+	}
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("expected one expression")
+	}
+	expr, ok := stmts[0].(*ast.Expression)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression")
+	}
+	return d.interpreter.Running().Evaluate(expr.Expression)
+}
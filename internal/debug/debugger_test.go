@@ -0,0 +1,227 @@
+package debug_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/debug"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// parseSource scans and parses source, failing the test on errors.
+func parseSource(t *testing.T, source string) []ast.Stmt {
+	t.Helper()
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+// stop is where and why execution stopped.
+type stop struct {
+	reason string
+	line   int
+}
+
+// stepper is a Frontend resuming each stop with the next of its commands: i steps into, o over, u out, and c
+// continues. Once the commands run out, it continues.
+type stepper struct {
+	commands string
+	stops    []stop
+}
+
+func (s *stepper) Pause(d *debug.Debugger, reason string) error {
+	s.stops = append(s.stops, stop{reason, d.Line()})
+	command := byte('c')
+	if len(s.commands) > 0 {
+		command, s.commands = s.commands[0], s.commands[1:]
+	}
+	switch command {
+	case 'i':
+		d.StepInto()
+	case 'o':
+		d.StepOver()
+	case 'u':
+		d.StepOut()
+	default:
+		d.Continue()
+	}
+	return nil
+}
+
+func TestStepping(t *testing.T) {
+	const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = add(1, 2);
+print x;
+x = add(x, 1);
+print x;
+`
+	tests := []struct {
+		name        string
+		stopOnEntry bool
+		breakpoints []int
+		commands    string
+		want        []stop
+	}{
+		{"entry", true, nil, "", []stop{{"entry", 1}}},
+		{"breakpoints", false, []int{2, 8}, "", []stop{{"breakpoint", 2}, {"breakpoint", 2}, {"breakpoint", 8}}},
+		{"breakpoint in block", false, []int{4}, "", nil},
+		{"step into", true, nil, "iiiiii", []stop{
+			{"entry", 1}, {"step", 5}, {"step", 2}, {"step", 3}, {"step", 6}, {"step", 7}, {"step", 2},
+		}},
+		{"step over", true, nil, "oooo", []stop{{"entry", 1}, {"step", 5}, {"step", 6}, {"step", 7}, {"step", 8}}},
+		{"step over in function", false, []int{2}, "oo", []stop{{"breakpoint", 2}, {"step", 3}, {"step", 6}, {"breakpoint", 2}}},
+		{"step out", false, []int{2}, "u", []stop{{"breakpoint", 2}, {"step", 6}, {"breakpoint", 2}}},
+		{"step out of program", false, []int{6}, "u", []stop{{"breakpoint", 6}}},
+		{"breakpoint while stepping over", false, []int{5, 3}, "o", []stop{{"breakpoint", 5}, {"breakpoint", 3}, {"breakpoint", 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &stepper{commands: tt.commands}
+			d := debug.New(s, tt.stopOnEntry)
+			d.SetBreakpoints(tt.breakpoints)
+			if _, err := d.Run(parseSource(t, source), engine.WithStdout(io.Discard)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.stops, tt.want) {
+				t.Errorf("stopped at %v, want %v", s.stops, tt.want)
+			}
+		})
+	}
+}
+
+// inspector is a Frontend recording the frames and scopes at its first stop, and evaluating expressions there.
+type inspector struct {
+	exprs  []string
+	frames []debug.Frame
+	scopes []map[string]any
+	values []any
+	errs   []error
+}
+
+func (in *inspector) Pause(d *debug.Debugger, reason string) error {
+	if in.frames == nil {
+		in.frames, in.scopes = d.Frames(), d.Scopes()
+		for _, expr := range in.exprs {
+			v, err := d.Evaluate(expr)
+			in.values, in.errs = append(in.values, v), append(in.errs, err)
+		}
+	}
+	d.Continue()
+	return nil
+}
+
+func TestInspect(t *testing.T) {
+	const source = `var total = 0;
+fun count(n) {
+  if (n == 0) {
+    var done = true;
+    return total;
+  }
+  total = total + n;
+  return count(n - 1);
+}
+print count(2);
+`
+	in := &inspector{exprs: []string{"n + total", "total = 100", "done", "print 1;", "1 +"}}
+	d := debug.New(in, false)
+	d.SetBreakpoint(5)
+	var out bytes.Buffer
+	if _, err := d.Run(parseSource(t, source), engine.WithStdout(&out)); err != nil {
+		t.Fatal(err)
+	}
+
+	wantFrames := []debug.Frame{{Name: "count", Line: 5}, {Name: "count", Line: 8}, {Name: "count", Line: 8}, {Name: "main", Line: 10}}
+	if !reflect.DeepEqual(in.frames, wantFrames) {
+		t.Errorf("Frames() = %v, want %v", in.frames, wantFrames)
+	}
+	// the block of the if, the body of the function, its parameters, and the global scope with total and count
+	wantScopes := []map[string]any{{"done": true}, {}, {"n": 0.0}}
+	if len(in.scopes) != 4 || !reflect.DeepEqual(in.scopes[:3], wantScopes) || in.scopes[3]["total"] != 3.0 ||
+		len(in.scopes[3]) != 2 {
+		t.Errorf("Scopes() = %v, want %v and the globals", in.scopes, wantScopes)
+	}
+	if want := []any{3.0, 100.0, true}; !reflect.DeepEqual(in.values[:3], want) {
+		t.Errorf("Evaluate() = %v, want %v", in.values[:3], want)
+	}
+	for n, err := range in.errs {
+		if failed := err != nil; failed != (n >= 3) {
+			t.Errorf("Evaluate(%q) error = %v", in.exprs[n], err)
+		}
+	}
+	// the assignment changed the program's variable
+	if out.String() != "100\n" {
+		t.Errorf("program printed %q, want %q", out.String(), "100\n")
+	}
+}
+
+// recorder is a Frontend recording the frames, the scopes and the value of an expression at each stop.
+type recorder struct {
+	expr   string
+	frames [][]debug.Frame
+	scopes [][]map[string]any
+	values []any
+}
+
+func (r *recorder) Pause(d *debug.Debugger, reason string) error {
+	r.frames = append(r.frames, d.Frames())
+	r.scopes = append(r.scopes, d.Scopes())
+	v, err := d.Evaluate(r.expr)
+	if err != nil {
+		return err
+	}
+	r.values = append(r.values, v)
+	d.Continue()
+	return nil
+}
+
+// TestGenerator checks that a stop in the body of a generator shows the generator inside the call that resumed it,
+// with the generator's own variables.
+func TestGenerator(t *testing.T) {
+	const source = `fun gen(n) {
+  var i = n * 10;
+  yield i;
+  yield i + 1;
+}
+var g = gen(1);
+print g.next();
+fun twice() { return g.next(); }
+print twice();
+`
+	r := &recorder{expr: "i"}
+	d := debug.New(r, false)
+	d.SetBreakpoint(3)
+	d.SetBreakpoint(4)
+	if _, err := d.Run(parseSource(t, source), engine.WithStdout(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]debug.Frame{
+		{{Name: "gen", Line: 3}, {Name: "main", Line: 7}},
+		{{Name: "gen", Line: 4}, {Name: "twice", Line: 8}, {Name: "main", Line: 9}},
+	}
+	if !reflect.DeepEqual(r.frames, want) {
+		t.Errorf("frames = %v, want %v", r.frames, want)
+	}
+	for n, scopes := range r.scopes {
+		if len(scopes) < 2 || scopes[0]["i"] != 10.0 || scopes[1]["n"] != 1.0 {
+			t.Errorf("stop %v: scopes = %v, want i and n of the generator", n+1, scopes)
+		}
+	}
+	if !reflect.DeepEqual(r.values, []any{10.0, 10.0}) {
+		t.Errorf("i evaluates to %v, want 10 at each stop", r.values)
+	}
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// Callable is a value that can be called: a Lox function or a native function.
+type Callable interface {
+	// Arity is the number of arguments the callable takes, or -1 if it takes any number.
+	Arity() int
+	Call(i *Interpreter, args []any) (any, error)
+}
+
+// Function is a function declared in Lox, closing over the scope it was declared in.
+type Function struct {
+	Declaration *ast.Function
+	closure     *Environment
+}
+
+func (f *Function) Arity() int {
+	return len(f.Declaration.Params)
+}
+
+func (f *Function) Call(i *Interpreter, args []any) (any, error) {
+	size := environmentSize
+	for _, param := range f.Declaration.Params {
+		size += bindingSize + len(param.Lexeme)
+	}
+	if err := i.alloc(size); err != nil {
+		return nil, err
+	}
+	env := f.closure.Wrap()
+	for n, param := range f.Declaration.Params {
+		env.Define(param.Lexeme, args[n])
+	}
+	if f.Declaration.Generator {
+		return i.newGenerator(f, env), nil
+	}
+
+	enclosing := i.env
+	i.env = env
+	defer func() {
+		i.env = enclosing
+	}()
+	_, err := i.execute(f.Declaration.Body)
+	if r, ok := err.(*returnValue); ok {
+		return r.value, nil
+	}
+	return nil, err
+}
+
+func (f *Function) String() string {
+	return "<fn " + f.name() + ">"
+}
+
+// name returns the name of the function, or lambda for an anonymous function.
+func (f *Function) name() string {
+	if f.Declaration.Name.Lexeme == "" {
+		return "lambda"
+	}
+	return f.Declaration.Name.Lexeme
+}
+
+// Native is a function implemented in Go. Errors it returns that are not runtime errors become runtime errors at the
+// call site, wrapping the original error. A native is only called if the interpreter grants the capabilities it needs.
+// Params of -1 lets it take any number of arguments.
+type Native struct {
+	Name   string
+	Params int
+	Needs  Capability
+	Fn     func(i *Interpreter, args []any) (any, error)
+}
+
+func (n *Native) Arity() int {
+	return n.Params
+}
+
+func (n *Native) Call(i *Interpreter, args []any) (any, error) {
+	if err := i.permit(n); err != nil {
+		return nil, err
+	}
+	return n.Fn(i, args)
+}
+
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+// returnValue carries the value of a return statement, as an error, up to the function call it returns from.
+type returnValue struct {
+	value any
+}
+
+func (r *returnValue) Error() string {
+	return fmt.Sprintf("return %v outside of a function", r.value)
+}
+
+// builtins are the natives defined in every interpreter's global scope.
+var builtins = append(append(append([]*Native{}, hostNatives...), concurrencyNatives...), iterationNatives...)
+
+// Builtins returns the names of the natives defined in every interpreter's global scope.
+func Builtins() []string {
+	names := make([]string, len(builtins))
+	for i, n := range builtins {
+		names[i] = n.Name
+	}
+	return names
+}
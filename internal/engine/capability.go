@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Capability is a kind of access to the host that a native function needs. An interpreter grants no capabilities
+// unless given the options that allow them, so a script calling a native that needs one it lacks fails with a
+// runtime error; the interpreters of imported modules share the capabilities of the importer.
+type Capability int
+
+const (
+	Clock Capability = 1 << iota // reading the current time
+	Env                          // reading environment variables
+	Stdin                        // reading standard input
+	FS                           // reading and writing files, and importing modules, under the paths allowed
+	Tasks                        // spawning tasks, up to the number allowed at once
+)
+
+func (c Capability) String() string {
+	switch c {
+	case Clock:
+		return "clock"
+	case Env:
+		return "env"
+	case Stdin:
+		return "stdin"
+	case FS:
+		return "fs"
+	case Tasks:
+		return "tasks"
+	}
+	return fmt.Sprintf("Capability(%d)", int(c))
+}
+
+// PermissionError is the error of a native function called without a capability it needs.
+type PermissionError struct {
+	Native     string
+	Capability Capability
+	Path       string // the path denied, for the fs capability
+}
+
+func (e *PermissionError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%v: permission denied: %v capability does not allow %v", e.Native, e.Capability, e.Path)
+	}
+	return fmt.Sprintf("%v: permission denied: %v capability not granted", e.Native, e.Capability)
+}
+
+// capabilities are what an interpreter, and the interpreters of the modules it imports, may access.
+type capabilities struct {
+	granted  Capability
+	roots    []string   // absolute directories and files FS allows
+	anyPath  bool       // FS allows every path
+	stdinMu  sync.Mutex // guards stdin, which tasks share
+	stdin    *bufio.Reader
+	maxTasks int64        // how many tasks Tasks allows at once
+	tasks    atomic.Int64 // tasks running
+}
+
+// AllowClock lets scripts read the current time, with clock().
+func AllowClock() Option {
+	return allow(Clock)
+}
+
+// AllowEnv lets scripts read environment variables, with getenv(name).
+func AllowEnv() Option {
+	return allow(Env)
+}
+
+// AllowStdin lets scripts read standard input, or the reader given to WithStdin, with readLine().
+func AllowStdin() Option {
+	return allow(Stdin)
+}
+
+// AllowFS lets scripts read and write the files under paths, with readFile(path) and writeFile(path, text), and import
+// the modules among them.
+// Relative paths, both here and in scripts, are relative to the working directory. Symbolic links are followed before
+// paths are compared, so a link under paths reaches only files that are themselves under paths.
+func AllowFS(paths ...string) Option {
+	return func(i *Interpreter) {
+		c := i.capability()
+		c.granted |= FS
+		for _, p := range paths {
+			if root, err := realPath(p); err == nil {
+				c.roots = append(c.roots, root)
+			}
+		}
+	}
+}
+
+// AllowTasks lets scripts run functions concurrently with spawn(fn, args...), with up to n tasks running at once.
+func AllowTasks(n int) Option {
+	return func(i *Interpreter) {
+		c := i.capability()
+		c.granted |= Tasks
+		c.maxTasks = int64(n)
+	}
+}
+
+// maxTasks is how many tasks AllowAll lets run at once.
+const maxTasks = 10000
+
+// AllowAll grants every capability, with access to the whole file system and up to 10000 tasks running at once.
+func AllowAll() Option {
+	return func(i *Interpreter) {
+		c := i.capability()
+		c.granted |= Clock | Env | Stdin | FS | Tasks
+		c.anyPath = true
+		c.maxTasks = maxTasks
+	}
+}
+
+// WithStdin makes readLine() read from r instead of os.Stdin.
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.capability().stdin = bufio.NewReader(r)
+	}
+}
+
+// withCapabilities shares the capabilities of an importing interpreter with the interpreter of a module.
+func withCapabilities(c *capabilities) Option {
+	return func(i *Interpreter) {
+		i.caps = c
+	}
+}
+
+func allow(c Capability) Option {
+	return func(i *Interpreter) {
+		i.capability().granted |= c
+	}
+}
+
+func (i *Interpreter) capability() *capabilities {
+	if i.caps == nil {
+		i.caps = &capabilities{}
+	}
+	return i.caps
+}
+
+// permit returns an error unless the interpreter grants the capability that native n needs.
+func (i *Interpreter) permit(n *Native) error {
+	if n.Needs == 0 || i.caps != nil && i.caps.granted&n.Needs == n.Needs {
+		return nil
+	}
+	return &PermissionError{Native: n.Name, Capability: n.Needs}
+}
+
+// allowPath returns the absolute form of path if it is under one of the roots allowed by AllowFS.
+func (i *Interpreter) allowPath(native, path string) (string, error) {
+	if i.caps.anyPath {
+		return filepath.Abs(path)
+	}
+	real, err := realPath(path)
+	if err != nil {
+		return "", &PermissionError{Native: native, Capability: FS, Path: path}
+	}
+	for _, root := range i.caps.roots {
+		if real == root || strings.HasPrefix(real, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return real, nil
+		}
+	}
+	return "", &PermissionError{Native: native, Capability: FS, Path: path}
+}
+
+// realPath returns the absolute form of path with every symbolic link in it followed. A path that does not exist yet,
+// such as a file about to be written, is resolved through the directory it would be in; a link to nowhere is an
+// error, as where writing through it would land cannot be checked.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return real, nil
+	}
+	if _, lerr := os.Lstat(abs); !errors.Is(err, fs.ErrNotExist) || lerr == nil {
+		return "", err
+	}
+	dir, err := realPath(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// stringArg returns argument n of a native call, which must be a string.
+func stringArg(native string, args []any, n int) (string, error) {
+	s, ok := args[n].(string)
+	if !ok {
+		return "", fmt.Errorf("%v: argument %v must be a string", native, n+1)
+	}
+	return s, nil
+}
+
+// hostNatives are the natives that reach outside the interpreter, each needing a capability.
+var hostNatives = []*Native{
+	{Name: "clock", Needs: Clock, Fn: func(i *Interpreter, args []any) (any, error) {
+		return float64(time.Now().UnixNano()) / 1e9, nil
+	}},
+	{Name: "getenv", Params: 1, Needs: Env, Fn: func(i *Interpreter, args []any) (any, error) {
+		name, err := stringArg("getenv", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v, nil
+		}
+		return nil, nil
+	}},
+	{Name: "readLine", Needs: Stdin, Fn: func(i *Interpreter, args []any) (any, error) {
+		i.caps.stdinMu.Lock()
+		if i.caps.stdin == nil {
+			i.caps.stdin = bufio.NewReader(os.Stdin)
+		}
+		line, err := i.caps.stdin.ReadString('\n')
+		i.caps.stdinMu.Unlock()
+		if err == io.EOF && line == "" {
+			return nil, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if err := i.alloc(len(line)); err != nil {
+			return nil, err
+		}
+		return line, nil
+	}},
+	{Name: "readFile", Params: 1, Needs: FS, Fn: func(i *Interpreter, args []any) (any, error) {
+		path, err := stringArg("readFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := i.allowPath("readFile", path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.alloc(int(info.Size())); err != nil {
+			return nil, err
+		}
+		bytes, err := os.ReadFile(abs)
+		if err != nil {
+			return nil, err
+		}
+		return string(bytes), nil
+	}},
+	{Name: "writeFile", Params: 2, Needs: FS, Fn: func(i *Interpreter, args []any) (any, error) {
+		path, err := stringArg("writeFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		text, err := stringArg("writeFile", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := i.allowPath("writeFile", path)
+		if err != nil {
+			return nil, err
+		}
+		return nil, os.WriteFile(abs, []byte(text), 0o644)
+	}},
+}
@@ -0,0 +1,123 @@
+package engine_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func TestCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	if err := os.Mkdir(allowed, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(allowed, "in.txt"), []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(allowed, "lib.lox"), []byte(`var name = "lib";`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a module that fails to parse, with an error quoting its contents
+	if err := os.WriteFile(filepath.Join(dir, "secret.lox"), []byte("print hunter2 hunter2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// links inside the allowed directory to outside it, and a link to the allowed directory
+	for link, target := range map[string]string{
+		filepath.Join(allowed, "secret.txt"): filepath.Join(dir, "secret.txt"),
+		filepath.Join(allowed, "secret.lox"): filepath.Join(dir, "secret.lox"),
+		filepath.Join(allowed, "parent"):     dir,
+		filepath.Join(allowed, "dangling"):   filepath.Join(dir, "created.txt"),
+		filepath.Join(dir, "link"):           allowed,
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("cannot create symbolic links: %v", err)
+		}
+	}
+	t.Setenv("LOX_CAPABILITY_TEST", "value")
+	imports := func(opts ...engine.Option) []engine.Option {
+		return append(opts, engine.WithLoader(engine.NewLoader(scan.Scan, parse.Parse, nil)),
+			engine.WithPath(filepath.Join(allowed, "main.lox")))
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		opts   []engine.Option
+		want   string            // output, when the script succeeds
+		denied engine.Capability // capability the script is refused, if any
+	}{
+		{"clock denied", "print clock() > 0;", nil, "", engine.Clock},
+		{"clock allowed", "print clock() > 0;", []engine.Option{engine.AllowClock()}, "true\n", 0},
+		{"env denied", `print getenv("LOX_CAPABILITY_TEST");`, []engine.Option{engine.AllowClock()}, "", engine.Env},
+		{"env allowed", `print getenv("LOX_CAPABILITY_TEST");`, []engine.Option{engine.AllowEnv()}, "value\n", 0},
+		{"stdin denied", "print readLine();", nil, "", engine.Stdin},
+		{"stdin allowed", "print readLine(); print readLine(); print readLine();",
+			[]engine.Option{engine.AllowStdin(), engine.WithStdin(strings.NewReader("one\ntwo"))}, "one\ntwo\n<nil>\n", 0},
+		{"fs denied", `print readFile("` + filepath.Join(allowed, "in.txt") + `");`, []engine.Option{engine.AllowEnv()}, "", engine.FS},
+		{"fs allowed", `print readFile("` + filepath.Join(allowed, "in.txt") + `");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "contents\n", 0},
+		{"fs outside allowed paths", `print readFile("` + filepath.Join(dir, "secret.txt") + `");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "", engine.FS},
+		{"fs escaping allowed paths", `print readFile("` + filepath.Join(allowed, "..", "secret.txt") + `");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "", engine.FS},
+		{"fs write", `writeFile("` + filepath.Join(allowed, "out.txt") + `", "written"); print readFile("` +
+			filepath.Join(allowed, "out.txt") + `");`, []engine.Option{engine.AllowFS(allowed)}, "written\n", 0},
+		{"fs link out of allowed paths", `print readFile("` + filepath.Join(allowed, "secret.txt") + `");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "", engine.FS},
+		{"fs write through linked directory", `writeFile("` + filepath.Join(allowed, "parent", "created.txt") + `", "x");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "", engine.FS},
+		{"fs write through dangling link", `writeFile("` + filepath.Join(allowed, "dangling") + `", "x");`,
+			[]engine.Option{engine.AllowFS(allowed)}, "", engine.FS},
+		{"fs allowed through link", `print readFile("` + filepath.Join(allowed, "in.txt") + `");`,
+			[]engine.Option{engine.AllowFS(filepath.Join(dir, "link"))}, "contents\n", 0},
+		{"import denied", `import "lib.lox" as lib;`, imports(engine.AllowClock()), "", engine.FS},
+		{"import allowed", `import "lib.lox" as lib; print lib.name;`, imports(engine.AllowFS(allowed)), "lib\n", 0},
+		{"import outside allowed paths", `import "../secret.lox" as secret;`, imports(engine.AllowFS(allowed)), "", engine.FS},
+		{"import link out of allowed paths", `import "secret.lox" as secret;`, imports(engine.AllowFS(allowed)), "", engine.FS},
+		{"tasks denied", "fun f() {} spawn(f);", nil, "", engine.Tasks},
+		{"tasks allowed", "fun f() { return 1; } print wait(spawn(f));", []engine.Option{engine.AllowTasks(1)}, "1\n", 0},
+		{"all", `print readFile("` + filepath.Join(dir, "secret.txt") + `");`, []engine.Option{engine.AllowAll()}, "secret\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			i := engine.NewInterpreter(append(tt.opts, engine.WithStdout(&out))...)
+			_, err := i.Interpret(mustParse(t, tt.source))
+
+			if tt.denied == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if out.String() != tt.want {
+					t.Errorf("output = %q, want %q", out.String(), tt.want)
+				}
+				return
+			}
+			var runtimeErr *engine.RuntimeError
+			var permissionErr *engine.PermissionError
+			if !errors.As(err, &runtimeErr) || !errors.As(err, &permissionErr) {
+				t.Fatalf("Interpret() error = %v, want a runtime error for a denied capability", err)
+			}
+			if permissionErr.Capability != tt.denied {
+				t.Errorf("denied capability = %v, want %v", permissionErr.Capability, tt.denied)
+			}
+			if strings.Contains(err.Error(), "hunter2") {
+				t.Errorf("Interpret() error = %v, which quotes a file outside the allowed paths", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "created.txt")); err == nil {
+				t.Errorf("script created %v, outside the allowed paths", filepath.Join(dir, "created.txt"))
+			}
+		})
+	}
+}
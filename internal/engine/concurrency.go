@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Lox programs run functions concurrently with spawn(fn, args...), which returns a Task, and communicate through
+// channels made with channel(capacity) and used with send, recv, close and select.
+//
+// A task runs in an interpreter of its own, on a snapshot of everything the function and its arguments can reach:
+// the environments of their closures, including the globals, and the modules they use are copied when the task is
+// spawned. The task sees the values variables had at that moment, and its assignments are only seen by itself, so
+// tasks never share variables; they share only channels and tasks, which are safe for concurrent use. Values sent
+// over a channel are copied the same way when they are sent. Print statements of tasks write to the same output as
+// the program, one whole line at a time. Tasks spend the step and
+// memory budgets of the program, stop at its timeout and context, and count the depth of their own calls; they import
+// modules through a loader of their own, and run without hooks. Spawning needs the Tasks capability, which bounds how
+// many tasks run at once. The program does not wait for its tasks to finish, unless it calls wait.
+
+// Channel is a channel value, passing values between tasks.
+type Channel struct {
+	c      chan any
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *Channel) String() string {
+	return "<channel>"
+}
+
+// Task is a function running concurrently, returned by spawn.
+type Task struct {
+	done   chan struct{}
+	result any
+	err    error
+}
+
+func (t *Task) String() string {
+	return "<task>"
+}
+
+// TaskLimitError is returned when a program spawns more tasks at once than AllowTasks allows.
+type TaskLimitError struct {
+	Limit int
+}
+
+func (e *TaskLimitError) Error() string {
+	return fmt.Sprintf("task limit of %v tasks running at once exceeded", e.Limit)
+}
+
+func (e *TaskLimitError) limit() {}
+
+// Selection is the outcome of select: the channel a value was received from, the value, and whether the channel was
+// still open, read as its properties channel, value and ok.
+type Selection struct {
+	Channel *Channel
+	Value   any
+	OK      bool
+}
+
+func (s *Selection) Get(name string) (any, bool) {
+	switch name {
+	case "channel":
+		return s.Channel, true
+	case "value":
+		return s.Value, true
+	case "ok":
+		return s.OK, true
+	}
+	return nil, false
+}
+
+func (s *Selection) String() string {
+	return "<selection>"
+}
+
+// lockedWriter serializes the writes of a program and its tasks to a shared output.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// snapshot copies environments, with the functions and modules that refer to them, for a spawned task. Each is
+// copied once, so values that were the same remain the same in the copy.
+type snapshot map[any]any
+
+func (s snapshot) env(e *Environment) *Environment {
+	if e == nil {
+		return nil
+	}
+	if c, ok := s[e]; ok {
+		return c.(*Environment)
+	}
+	c := &Environment{values: make(map[string]any, len(e.values))}
+	s[e] = c
+	c.enclosing = s.env(e.enclosing)
+	for name, v := range e.values {
+		c.values[name] = s.value(v)
+	}
+	return c
+}
+
+func (s snapshot) value(v any) any {
+	if c, ok := s[v]; ok {
+		return c
+	}
+	switch v := v.(type) {
+	case *Function:
+		c := &Function{Declaration: v.Declaration}
+		s[v] = c
+		c.closure = s.env(v.closure)
+		return c
+	case *Module:
+		c := &Module{Path: v.Path}
+		s[v] = c
+		c.env = s.env(v.env)
+		return c
+	case *Generator:
+		// a suspended body can't be copied
+		c := &Generator{fn: v.fn, foreign: true}
+		s[v] = c
+		return c
+	}
+	return v
+}
+
+// lockOutput makes the outputs of the interpreter safe to share with the tasks it spawns, and with the interpreters of
+// modules it imports, which may spawn tasks too.
+func (i *Interpreter) lockOutput() {
+	if _, ok := i.stdout.(*lockedWriter); !ok {
+		i.stdout = &lockedWriter{w: i.stdout}
+	}
+	if i.trace != nil {
+		if _, ok := i.trace.(*lockedWriter); !ok {
+			i.trace = &lockedWriter{w: i.trace}
+		}
+	}
+}
+
+// spawn starts fn with args in a new task, unless as many tasks as the interpreter allows are running.
+func (i *Interpreter) spawn(fn Callable, args []any) (*Task, error) {
+	if i.caps.tasks.Add(1) > i.caps.maxTasks {
+		i.caps.tasks.Add(-1)
+		return nil, &TaskLimitError{Limit: int(i.caps.maxTasks)}
+	}
+	i.lockOutput()
+	s := make(snapshot)
+	fn = s.value(fn).(Callable)
+	copied := make([]any, len(args))
+	for n, arg := range args {
+		copied[n] = s.value(arg)
+	}
+	child := &Interpreter{env: s.env(i.env), path: i.path, stdout: i.stdout, trace: i.trace, caps: i.caps}
+	if i.loader != nil {
+		child.loader = NewLoader(i.loader.Scan, i.loader.Parse, i.loader.SearchPath)
+	}
+	if i.limits != nil {
+		child.limits = i.limits
+		child.limits.start()
+	}
+
+	t := &Task{done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		defer i.caps.tasks.Add(-1)
+		if child.limits != nil {
+			defer child.limits.stop()
+		}
+		t.result, t.err = fn.Call(child, copied)
+	}()
+	return t, nil
+}
+
+// block waits for one of cases, as reflect.Select does, unless the context or timeout of the interpreter stops the
+// wait first, with the error of that limit.
+func (i *Interpreter) block(cases []reflect.SelectCase) (int, reflect.Value, bool, error) {
+	n := len(cases)
+	ctxCase, timerCase := -1, -1
+	if l := i.limits; l != nil {
+		if l.ctx != nil {
+			ctxCase = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(l.ctx.Done())})
+		}
+		if l.timeout > 0 {
+			timer := time.NewTimer(time.Until(l.expiry()))
+			defer timer.Stop()
+			timerCase = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+		}
+	}
+	chosen, v, ok := reflect.Select(cases)
+	switch {
+	case chosen < n:
+		return chosen, v, ok, nil
+	case chosen == ctxCase:
+		return 0, reflect.Value{}, false, &CanceledError{err: i.limits.ctx.Err()}
+	case chosen == timerCase:
+		return 0, reflect.Value{}, false, &TimeoutError{Timeout: i.limits.timeout}
+	}
+	panic("engine: unexpected select case")
+}
+
+func channelArg(native string, args []any, n int) (*Channel, error) {
+	c, ok := args[n].(*Channel)
+	if !ok {
+		return nil, fmt.Errorf("%v: argument %v must be a channel", native, n+1)
+	}
+	return c, nil
+}
+
+func recvCase(c *Channel) reflect.SelectCase {
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.c)}
+}
+
+// received converts a value received through reflect; a closed channel gives nil.
+func received(v reflect.Value, ok bool) any {
+	if !ok {
+		return nil
+	}
+	return v.Interface()
+}
+
+// maxCapacity bounds the capacity of a channel, which Go allocates up front.
+const maxCapacity = 1 << 20
+
+var concurrencyNatives = []*Native{
+	{Name: "spawn", Params: -1, Needs: Tasks, Fn: func(i *Interpreter, args []any) (any, error) {
+		if len(args) == 0 {
+			return nil, errors.New("spawn: expected a function to run")
+		}
+		fn, ok := args[0].(Callable)
+		if !ok {
+			return nil, errors.New("spawn: argument 1 must be a function")
+		}
+		if arity := fn.Arity(); arity >= 0 && arity != len(args)-1 {
+			return nil, fmt.Errorf("spawn: %v expects %v arguments but got %v", fn, arity, len(args)-1)
+		}
+		return i.spawn(fn, args[1:])
+	}},
+	{Name: "wait", Params: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+		t, ok := args[0].(*Task)
+		if !ok {
+			return nil, errors.New("wait: argument 1 must be a task")
+		}
+		_, _, _, err := i.block([]reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.done)}})
+		if err != nil {
+			return nil, err
+		}
+		return t.result, t.err
+	}},
+	{Name: "channel", Params: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+		capacity, ok := args[0].(float64)
+		if !ok || !(capacity >= 0 && capacity <= maxCapacity) || capacity != math.Trunc(capacity) {
+			return nil, fmt.Errorf("channel: capacity must be a whole number from 0 to %v", maxCapacity)
+		}
+		if err := i.alloc(environmentSize + 16*int(capacity)); err != nil {
+			return nil, err
+		}
+		return &Channel{c: make(chan any, int(capacity))}, nil
+	}},
+	{Name: "send", Params: 2, Fn: func(i *Interpreter, args []any) (rslt any, err error) {
+		c, err := channelArg("send", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			// the channel was closed while the send waited
+			if r := recover(); r != nil {
+				if e, ok := r.(runtime.Error); !ok || e.Error() != "send on closed channel" {
+					panic(r)
+				}
+				rslt, err = nil, errors.New("send: channel is closed")
+			}
+		}()
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			return nil, errors.New("send: channel is closed")
+		}
+		// the receiver gets a snapshot, as a spawned task does, so that tasks still share no variables
+		sent := make(snapshot).value(args[1])
+		value := reflect.ValueOf(&sent).Elem()
+		_, _, _, err = i.block([]reflect.SelectCase{{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c.c), Send: value}})
+		return nil, err
+	}},
+	{Name: "recv", Params: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+		c, err := channelArg("recv", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		_, v, ok, err := i.block([]reflect.SelectCase{recvCase(c)})
+		if err != nil {
+			return nil, err
+		}
+		return received(v, ok), nil
+	}},
+	{Name: "close", Params: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+		c, err := channelArg("close", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return nil, errors.New("close: channel is already closed")
+		}
+		c.closed = true
+		close(c.c)
+		return nil, nil
+	}},
+	{Name: "select", Params: -1, Fn: func(i *Interpreter, args []any) (any, error) {
+		if len(args) == 0 {
+			return nil, errors.New("select: expected channels to wait on")
+		}
+		channels := make([]*Channel, len(args))
+		cases := make([]reflect.SelectCase, len(args))
+		for n := range args {
+			c, err := channelArg("select", args, n)
+			if err != nil {
+				return nil, err
+			}
+			channels[n], cases[n] = c, recvCase(c)
+		}
+		chosen, v, ok, err := i.block(cases)
+		if err != nil {
+			return nil, err
+		}
+		return &Selection{Channel: channels[chosen], Value: received(v, ok), OK: ok}, nil
+	}},
+}
@@ -0,0 +1,204 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+)
+
+// TestConcurrency runs scripts whose output does not depend on how their tasks are scheduled. Run it with -race.
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"wait", "fun add(a, b) { return a + b; } print wait(spawn(add, 1, 2));", "3\n"},
+		{"producer consumer", `
+			fun produce(c, n) { var i = 1; while (i <= n) { send(c, i); i = i + 1; } close(c); }
+			var c = channel(0);
+			spawn(produce, c, 100);
+			var sum = 0;
+			var v = recv(c);
+			while (v != nil) { sum = sum + v; v = recv(c); }
+			print sum;`, "5050\n"},
+		{"workers", `
+			fun work(jobs, results) { var j = recv(jobs); while (j != nil) { send(results, j * j); j = recv(jobs); } }
+			var jobs = channel(10);
+			var results = channel(10);
+			var w = 0;
+			while (w < 4) { spawn(work, jobs, results); w = w + 1; }
+			var i = 1;
+			while (i <= 10) { send(jobs, i); i = i + 1; }
+			close(jobs);
+			var sum = 0;
+			i = 0;
+			while (i < 10) { sum = sum + recv(results); i = i + 1; }
+			print sum;`, "385\n"},
+		{"assignments stay in the task", `
+			var x = 1;
+			fun set() { x = 2; return x; }
+			print wait(spawn(set));
+			print x;`, "2\n1\n"},
+		{"task sees values at spawn", `
+			var x = "before";
+			var ready = channel(0);
+			fun get() { recv(ready); return x; }
+			var t = spawn(get);
+			x = "after";
+			send(ready, true);
+			print wait(t);`, "before\n"},
+		{"closures are copied", `
+			fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+			var c = counter();
+			c();
+			print wait(spawn(c));
+			print c();`, "2\n2\n"},
+		{"sent closures are copied", `
+			fun make(c) {
+				var n = 0;
+				fun inc() { n = n + 1; return n; }
+				send(c, inc);
+				var i = 0;
+				while (i < 100) { n = n + 1; i = i + 1; }
+			}
+			var c = channel(0);
+			spawn(make, c);
+			var inc = recv(c);
+			var i = 0;
+			while (i < 100) { inc(); i = i + 1; }
+			print inc();`, "101\n"},
+		{"select", `
+			var a = channel(1);
+			var b = channel(1);
+			send(b, "hello");
+			var s = select(a, b);
+			print s.channel == b;
+			print s.value;
+			print s.ok;
+			close(a);
+			s = select(a);
+			print s.ok;`, "true\nhello\ntrue\nfalse\n"},
+		{"recv on closed channel", "var c = channel(1); send(c, 1); close(c); print recv(c); print recv(c);", "1\n<nil>\n"},
+		{"print from tasks", `
+			fun say(done) { print "line"; send(done, true); }
+			var done = channel(0);
+			var i = 0;
+			while (i < 8) { spawn(say, done); i = i + 1; }
+			while (i > 0) { recv(done); i = i - 1; }`, strings.Repeat("line\n", 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			i := engine.NewInterpreter(engine.WithStdout(&out), engine.WithTimeout(5*time.Second), engine.AllowTasks(10))
+			if _, err := i.Interpret(mustParse(t, tt.source)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrencyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"task fails", `fun f() { return 1 + nil; } wait(spawn(f));`, "not supported"},
+		{"send on closed channel", "var c = channel(1); close(c); send(c, 1);", "send: channel is closed"},
+		{"close twice", "var c = channel(0); close(c); close(c);", "close: channel is already closed"},
+		{"spawn arity", "fun f(a) {} spawn(f);", "expects 1 arguments but got 0"},
+		{"spawn non-function", "spawn(1);", "argument 1 must be a function"},
+		{"channel capacity", "channel(-1);", "capacity must be a whole number"},
+		{"channel fraction", "channel(1.5);", "capacity must be a whole number"},
+		{"channel too large", "channel(1000000000000000);", "capacity must be a whole number from 0 to 1048576"},
+		{"channel NaN", "channel(0/0);", "capacity must be a whole number"},
+		{"missing property", "var c = channel(1); send(c, 1); select(c).nope;", "has no property nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := engine.NewInterpreter(engine.WithStdout(io.Discard), engine.WithTimeout(5*time.Second), engine.AllowTasks(10))
+			_, err := i.Interpret(mustParse(t, tt.source))
+			var runtimeErr *engine.RuntimeError
+			if !errors.As(err, &runtimeErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Interpret() error = %v, want a runtime error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestBlockedTasksStop checks that limits stop programs blocked on channels that never become ready.
+func TestBlockedTasksStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	i := engine.NewInterpreter(engine.WithContext(ctx))
+	_, err := i.Interpret(mustParse(t, "recv(channel(0));"))
+	if !errors.Is(err, context.Canceled) || !engine.IsLimit(err) {
+		t.Errorf("canceled recv: error = %v, want a CanceledError", err)
+	}
+
+	i = engine.NewInterpreter(engine.WithTimeout(10*time.Millisecond), engine.AllowTasks(1))
+	_, err = i.Interpret(mustParse(t, "fun block(c) { recv(c); } wait(spawn(block, channel(0)));"))
+	var timeout *engine.TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("wait on a blocked task: error = %v, want a TimeoutError", err)
+	}
+}
+
+// TestTaskLimits checks that tasks spend the budget of the program that spawned them, and that no more tasks run at
+// once than the program allows.
+func TestTaskLimits(t *testing.T) {
+	i := engine.NewInterpreter(engine.WithStdout(io.Discard), engine.WithMaxSteps(1000), engine.AllowTasks(10))
+	_, err := i.Interpret(mustParse(t, `
+		fun loop() { var i = 0; while (i < 100) i = i + 1; }
+		var tasks = channel(10);
+		var n = 0;
+		while (n < 10) { send(tasks, spawn(loop)); n = n + 1; }
+		while (n > 0) { wait(recv(tasks)); n = n - 1; }`))
+	var steps *engine.StepLimitError
+	if !errors.As(err, &steps) {
+		t.Errorf("tasks over the step budget: error = %v, want a StepLimitError", err)
+	}
+
+	i = engine.NewInterpreter(engine.WithStdout(io.Discard), engine.WithMaxMemory(10000), engine.AllowTasks(10))
+	_, err = i.Interpret(mustParse(t, `
+		fun grow() { var s = "."; var i = 0; while (i < 8) { s = s + s; i = i + 1; } }
+		var tasks = channel(10);
+		var n = 0;
+		while (n < 10) { send(tasks, spawn(grow)); n = n + 1; }
+		while (n > 0) { wait(recv(tasks)); n = n - 1; }`))
+	var memory *engine.ResourceExhaustedError
+	if !errors.As(err, &memory) {
+		t.Errorf("tasks over the memory budget: error = %v, want a ResourceExhaustedError", err)
+	}
+
+	i = engine.NewInterpreter(engine.WithStdout(io.Discard), engine.WithTimeout(5*time.Second), engine.AllowTasks(2))
+	_, err = i.Interpret(mustParse(t, `
+		var c = channel(0);
+		fun block() { recv(c); }
+		spawn(block);
+		spawn(block);
+		spawn(block);`))
+	var tasks *engine.TaskLimitError
+	if !errors.As(err, &tasks) || tasks.Limit != 2 {
+		t.Errorf("too many tasks: error = %v, want a TaskLimitError", err)
+	}
+
+	var out bytes.Buffer
+	i = engine.NewInterpreter(engine.WithStdout(&out), engine.WithTimeout(5*time.Second), engine.AllowTasks(1))
+	if _, err := i.Interpret(mustParse(t, `fun f() { return 1; } wait(spawn(f)); print wait(spawn(f));`)); err != nil {
+		t.Fatalf("tasks one after another: %v", err)
+	}
+	if out.String() != "1\n" {
+		t.Errorf("tasks one after another: printed %q, want %q", out.String(), "1\n")
+	}
+}
@@ -17,6 +17,15 @@ func (e *Environment) Unwrap() *Environment {
 	return e.enclosing
 }
 
+// Values returns a copy of the bindings defined directly in e.
+func (e *Environment) Values() map[string]any {
+	values := make(map[string]any, len(e.values))
+	for k, v := range e.values {
+		values[k] = v
+	}
+	return values
+}
+
 func (e *Environment) Define(name string, value any) {
 	e.values[name] = value
 }
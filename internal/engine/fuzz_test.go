@@ -0,0 +1,52 @@
+package engine_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// addSeeds adds the conformance scripts and the example scripts to the seed corpus of f.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{"../../testdata/conformance/*/*.lox", "../../scripts/*.lox", "../../script.lox"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(source))
+		}
+	}
+}
+
+// FuzzInterpret checks that interpreting any program that parses never panics, within limits on statements, call
+// depth and memory.
+func FuzzInterpret(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens, err := scan.Scan(source)
+		if err != nil {
+			return
+		}
+		stmts, err := parse.Parse(tokens)
+		if err != nil {
+			return
+		}
+		interpreter := engine.NewInterpreter(
+			engine.WithMaxSteps(10000),
+			engine.WithMaxDepth(1000),
+			engine.WithMaxMemory(1<<20),
+			engine.WithStdout(io.Discard),
+		)
+		interpreter.Interpret(stmts)
+	})
+}
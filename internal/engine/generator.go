@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// A function whose body yields, outside of the functions and lambdas declared in it, is a generator function: calling it binds
+// its arguments, runs none of its body, and returns a Generator. Each call of the generator's next method runs the
+// body up to its next yield statement and returns the value yielded. Once the body finishes, by running off its end
+// or returning, next returns nil; a for statement stops there, so it can tell a yielded nil from the end.
+//
+// The body runs on a goroutine of its own, in an interpreter sharing the options of the one that called the
+// generator function. Control passes between the goroutines, so only one of them runs at a time, and a generator
+// belongs to the task that created it. To hooks, the body runs inside the statement that called next, as the body of
+// a function runs inside the statement that called it; each time it resumes, it moves to the statement resuming it.
+
+// Generator is the iterator returned by calling a generator function.
+type Generator struct {
+	fn      *Function
+	env     *Environment // the scope of the body, with the arguments bound
+	in      *Interpreter // the interpreter the body runs in
+	co      *coroutine   // nil until the body starts
+	base    int          // the depth of the body's statements, that of the statement resuming it
+	done    bool
+	foreign bool // copied into a task from the program that created it
+}
+
+// coroutine hands control between a generator and the goroutine running its body.
+type coroutine struct {
+	resume chan bool // true to run to the next yield, false to stop at the current one
+	yield  chan yielded
+}
+
+type yielded struct {
+	value any
+	done  bool
+	err   error
+}
+
+// errStopped unwinds the body of a generator that was dropped while suspended.
+var errStopped = errors.New("generator stopped")
+
+func (i *Interpreter) newGenerator(fn *Function, env *Environment) *Generator {
+	in := *i
+	in.co, in.inner, in.open, in.depth = nil, nil, nil, 0
+	return &Generator{fn: fn, env: env, in: &in}
+}
+
+// generatorIterator iterates over a generator for a for statement, which resumes it. A generator runs once, so every
+// loop over it continues where the last one stopped.
+type generatorIterator struct {
+	g      *Generator
+	caller *Interpreter
+}
+
+func (it *generatorIterator) Next() (any, bool, error) {
+	return it.g.next(it.caller)
+}
+
+// next runs the body of g to its next yield, inside the statement caller is executing, returning the value yielded,
+// or false once the body has finished.
+func (g *Generator) next(caller *Interpreter) (any, bool, error) {
+	if g.foreign {
+		return nil, false, errors.New("next: generator was created by another task")
+	}
+	if g.done {
+		return nil, false, nil
+	}
+	g.in.depth += caller.depth - g.base
+	g.base = caller.depth
+	g.in.calls = caller.calls // the body has no calls executing while it is suspended
+	caller.inner = g.in
+	defer func() {
+		caller.inner = nil
+	}()
+	if g.co == nil {
+		g.start()
+	} else {
+		if h, ok := g.in.hook.(ResumeHook); ok {
+			h.Resume(g.in.open, g.base)
+		}
+		g.co.resume <- true
+	}
+	y := <-g.co.yield
+	if y.done {
+		g.done = true
+		runtime.SetFinalizer(g, nil)
+		return nil, false, y.err
+	}
+	return y.value, true, nil
+}
+
+func (g *Generator) start() {
+	co := &coroutine{resume: make(chan bool), yield: make(chan yielded)}
+	in, body := g.in, g.fn.Declaration.Body
+	in.co, in.env = co, g.env
+	g.co = co
+	go func() {
+		_, err := in.execute(body)
+		if err == errStopped {
+			return
+		}
+		if _, ok := err.(*returnValue); ok {
+			err = nil
+		}
+		co.yield <- yielded{done: true, err: err}
+	}()
+	// a generator dropped before it finishes stops its goroutine, as far as the goroutine does not keep it reachable
+	runtime.SetFinalizer(g, func(g *Generator) {
+		g.co.resume <- false
+	})
+}
+
+func (g *Generator) Get(name string) (any, bool) {
+	if name != "next" {
+		return nil, false
+	}
+	return &Native{Name: "next", Fn: func(i *Interpreter, args []any) (any, error) {
+		v, _, err := g.next(i)
+		return v, err
+	}}, true
+}
+
+func (g *Generator) String() string {
+	return "<generator " + g.fn.name() + ">"
+}
+
+func (i *Interpreter) VisitYieldStmt(stmt *ast.Yield) (any, error) {
+	if i.co == nil {
+		return nil, NewRuntimeError(stmt.Keyword, "can only yield from a generator")
+	}
+	var value any
+	if stmt.Value != nil {
+		var err error
+		value, err = i.Evaluate(stmt.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.co.yield <- yielded{value: value}
+	if !<-i.co.resume {
+		return nil, errStopped
+	}
+	return nil, nil
+}
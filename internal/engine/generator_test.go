@@ -0,0 +1,115 @@
+package engine_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+const counting = `
+fun count(n) {
+  var i = 0;
+  while (i < n) {
+    yield i;
+    i = i + 1;
+  }
+}
+fun naturals() {
+  var i = 0;
+  while (true) {
+    yield i;
+    i = i + 1;
+  }
+}
+`
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"for", "for (x in count(3)) print x;", "0\n1\n2\n"},
+		{"next", "var g = count(2); print g.next(); print g.next(); print g.next(); print g.next();", "0\n1\n<nil>\n<nil>\n"},
+		{"infinite", "var g = naturals(); g.next(); g.next(); print g.next();", "2\n"},
+		{"lazy", `fun f() { print "started"; yield 1; } var g = f(); print "created"; print g.next();`, "created\nstarted\n1\n"},
+		{"yield nil", "fun f() { yield nil; yield; yield 1; } for (x in f()) print x;", "<nil>\n<nil>\n1\n"},
+		{"return", "fun f() { yield 1; return; yield 2; } for (x in f()) print x;", "1\n"},
+		{"nested", `
+			for (a in count(2)) for (b in count(2)) print a + b;`, "0\n1\n1\n2\n"},
+		{"independent", "var a = count(3); var b = count(3); a.next(); print a.next(); print b.next();", "1\n0\n"},
+		{"closures per iteration", `
+			var first;
+			for (x in count(3)) {
+			  fun get() { return x; }
+			  if (x == 0) first = get;
+			}
+			print first();`, "0\n"},
+		{"nested functions", `
+			fun outer() { fun inner() { return 1; } yield inner() + 1; }
+			for (x in outer()) print x;`, "2\n"},
+		{"generator of generators", `
+			fun flatten(n) { for (i in count(n)) for (j in count(i)) yield j; }
+			for (x in flatten(4)) print x;`, "0\n0\n1\n0\n1\n2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			i := engine.NewInterpreter(engine.WithStdout(&out), engine.WithMaxSteps(10000))
+			if _, err := i.Interpret(mustParse(t, counting+tt.source)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"error in body", "fun f() { yield 1; yield 1 + nil; } for (x in f()) {}", "not supported"},
+		{"not iterable", "for (x in 1) {}", "can only iterate over strings, ranges and generators"},
+		{"no property", "count(1).prev();", "has no property prev"},
+		{"in a task", "var g = count(1); fun f() { return g.next(); } wait(spawn(f));", "generator was created by another task"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := engine.NewInterpreter(engine.WithStdout(io.Discard), engine.AllowTasks(10))
+			_, err := i.Interpret(mustParse(t, counting+tt.source))
+			var runtimeErr *engine.RuntimeError
+			if !errors.As(err, &runtimeErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Interpret() error = %v, want a runtime error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorLimits(t *testing.T) {
+	i := engine.NewInterpreter(engine.WithMaxSteps(1000))
+	_, err := i.Interpret(mustParse(t, counting+"for (x in naturals()) {}"))
+	var stepErr *engine.StepLimitError
+	if !errors.As(err, &stepErr) {
+		t.Errorf("Interpret() error = %v, want a StepLimitError", err)
+	}
+}
+
+func TestYieldOutsideFunction(t *testing.T) {
+	tokens, err := scan.Scan("yield 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse.Parse(tokens); err == nil || !strings.Contains(err.Error(), "Can't yield from top-level code.") {
+		t.Errorf("Parse() error = %v, want a top-level yield error", err)
+	}
+}
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/brentellingson/go-lox/internal/ast"
 	"github.com/brentellingson/go-lox/internal/token"
@@ -10,6 +13,7 @@ import (
 type RuntimeError struct {
 	token   token.Token
 	message string
+	err     error
 }
 
 func NewRuntimeError(token token.Token, message string) *RuntimeError {
@@ -24,15 +28,127 @@ func (e *RuntimeError) Error() string {
 	return fmt.Sprintf("runtime error: %v at line %v", e.message, e.token.Line)
 }
 
+// Line returns the source line where the error happened.
+func (e *RuntimeError) Line() int {
+	return e.token.Line
+}
+
+// Unwrap returns the error a native function failed with, if the runtime error came from one.
+func (e *RuntimeError) Unwrap() error {
+	return e.err
+}
+
+// Interpreter executes Lox programs. An Interpreter is not safe for concurrent use: it must only be used by one
+// goroutine at a time, since executing a program changes its current scope. Separate interpreters share nothing
+// mutable unless their options do, and syntax trees are only read, so a parsed program may be run by any number of
+// interpreters at once; Pool does so.
 type Interpreter struct {
-	env *Environment
+	env    *Environment
+	loader *Loader
+	path   string
+	hook   Hook
+	depth  int
+	calls  int // calls currently executing
+	stdout io.Writer
+	trace  io.Writer
+	limits *limits
+	caps   *capabilities
+	co     *coroutine   // set while running the body of a generator
+	open   []ast.Stmt   // the statements executing in the body of a generator, for a ResumeHook
+	inner  *Interpreter // the interpreter of the generator body this one is running, if any
+}
+
+// Hook observes execution, for debuggers and other tools.
+type Hook interface {
+	// BeforeStmt is called before each statement is executed. Depth counts the statements already executing around
+	// stmt, so the statements of a program have depth 0. Returning an error stops the program with that error.
+	BeforeStmt(stmt ast.Stmt, depth int) error
+}
+
+// AfterHook is implemented by hooks that also observe each statement finishing, whether or not it succeeded.
+type AfterHook interface {
+	Hook
+	AfterStmt(stmt ast.Stmt, depth int)
+}
+
+// ResumeHook is implemented by hooks that keep track of the statements executing. A generator suspends in the middle
+// of statements of its body, and continues them inside whichever statement next resumes it; before it does, Resume is
+// called with them, outermost first, and the depth of the first. They finish, for an AfterHook, as usual.
+type ResumeHook interface {
+	Hook
+	Resume(stmts []ast.Stmt, depth int)
+}
+
+// BranchHook is implemented by hooks that also observe conditional execution.
+type BranchHook interface {
+	Hook
+	// Branch is called each time an *ast.If, *ast.While, *ast.For or *ast.Logical decides whether to run its
+	// conditional part: the then branch, the loop body, or the right operand. Taken reports whether it does.
+	Branch(node ast.Node, taken bool)
+}
+
+// ModuleHook is implemented by hooks that also observe imported modules. Only these hooks are passed on to the
+// interpreters that run modules.
+type ModuleHook interface {
+	Hook
+	// LoadModule is called with the statements of a module before they run.
+	LoadModule(path string, stmts []ast.Stmt)
+}
+
+type Option func(*Interpreter)
+
+// WithLoader enables import statements, resolving and caching modules through l. Importing a module reads its file,
+// so it needs the FS capability.
+func WithLoader(l *Loader) Option {
+	return func(i *Interpreter) {
+		i.loader = l
+	}
+}
+
+// WithPath sets the file being interpreted; relative imports are resolved against its directory.
+func WithPath(path string) Option {
+	return func(i *Interpreter) {
+		i.path = path
+	}
 }
 
-func NewInterpreter() *Interpreter {
-	return &Interpreter{env: NewEnvironment()}
+// WithHook calls h before every statement the interpreter executes.
+func WithHook(h Hook) Option {
+	return func(i *Interpreter) {
+		i.hook = h
+	}
+}
+
+// WithStdout sends the output of print statements to w instead of os.Stdout.
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// WithNative defines n in the global scope, alongside the builtins.
+func WithNative(n *Native) Option {
+	return func(i *Interpreter) {
+		i.env.Define(n.Name, n)
+	}
+}
+
+func NewInterpreter(opts ...Option) *Interpreter {
+	i := &Interpreter{env: NewEnvironment(), stdout: os.Stdout}
+	for _, n := range builtins {
+		i.env.Define(n.Name, n)
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
 }
 
 func (i *Interpreter) Interpret(stmts []ast.Stmt) (any, error) {
+	if i.limits != nil {
+		i.limits.start()
+		defer i.limits.stop()
+	}
 	var rslt any
 	for _, stmt := range stmts {
 		var err error
@@ -45,7 +161,46 @@ func (i *Interpreter) Interpret(stmts []ast.Stmt) (any, error) {
 }
 
 func (i *Interpreter) execute(stmt ast.Stmt) (any, error) {
-	return stmt.Accept(i)
+	if i.limits != nil {
+		if err := i.limits.step(); err != nil {
+			return nil, err
+		}
+	}
+	if i.trace != nil {
+		i.tracef(ast.StmtLine(stmt), "%v", Describe(stmt))
+	}
+	if i.hook != nil {
+		if err := i.hook.BeforeStmt(stmt, i.depth); err != nil {
+			return nil, err
+		}
+	}
+	if i.co != nil && i.hook != nil {
+		i.open = append(i.open, stmt)
+		defer func() {
+			i.open = i.open[:len(i.open)-1]
+		}()
+	}
+	i.depth++
+	rslt, err := stmt.Accept(i)
+	i.depth--
+	if after, ok := i.hook.(AfterHook); ok {
+		after.AfterStmt(stmt, i.depth)
+	}
+	return rslt, err
+}
+
+// Environment returns the innermost scope of the code currently executing.
+func (i *Interpreter) Environment() *Environment {
+	return i.env
+}
+
+// Running returns the interpreter running the code currently executing: i, or the interpreter of the body of a
+// generator that i resumed.
+func (i *Interpreter) Running() *Interpreter {
+	for i.inner != nil {
+		i = i.inner
+	}
+	return i
 }
 
 func (i *Interpreter) Evaluate(expr ast.Expr) (any, error) {
@@ -53,7 +208,11 @@ func (i *Interpreter) Evaluate(expr ast.Expr) (any, error) {
 }
 
 func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) (any, error) {
-	return i.Evaluate(stmt.Expression)
+	rslt, err := i.Evaluate(stmt.Expression)
+	if err == nil && i.trace != nil {
+		i.tracef(ast.StmtLine(stmt), "  => %v", traceValue(rslt))
+	}
+	return rslt, err
 }
 
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) (any, error) {
@@ -61,7 +220,7 @@ func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(rslt)
+	fmt.Fprintln(i.stdout, rslt)
 	return nil, nil
 }
 
@@ -74,11 +233,20 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.Var) (any, error) {
 			return nil, err
 		}
 	}
+	if err := i.alloc(bindingSize + len(stmt.Name.Lexeme)); err != nil {
+		return nil, err
+	}
 	i.env.Define(stmt.Name.Lexeme, rslt)
+	if i.trace != nil {
+		i.tracef(stmt.Name.Line, "  %v = %v", stmt.Name.Lexeme, traceValue(rslt))
+	}
 	return nil, nil
 }
 
 func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) (any, error) {
+	if err := i.alloc(environmentSize); err != nil {
+		return nil, err
+	}
 	i.env = i.env.Wrap()
 	defer func() {
 		i.env = i.env.Unwrap()
@@ -100,7 +268,8 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.If) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	if isTruthy(v) {
+	i.branch(stmt, IsTruthy(v))
+	if IsTruthy(v) {
 		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
 		return i.execute(stmt.ElseBranch)
@@ -115,7 +284,8 @@ func (i *Interpreter) VisitWhileStmt(stmt *ast.While) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		if !isTruthy(v) {
+		i.branch(stmt, IsTruthy(v))
+		if !IsTruthy(v) {
 			break
 		}
 		rslt, err = i.execute(stmt.Body)
@@ -126,6 +296,73 @@ func (i *Interpreter) VisitWhileStmt(stmt *ast.While) (any, error) {
 	return rslt, nil
 }
 
+func (i *Interpreter) VisitImportStmt(stmt *ast.Import) (any, error) {
+	if i.loader == nil {
+		return nil, NewRuntimeError(stmt.Keyword, "imports are not enabled")
+	}
+	i.lockOutput()
+	opts := []Option{WithStdout(i.stdout), WithTrace(i.trace), withCalls(i.calls)}
+	if _, ok := i.hook.(ModuleHook); ok {
+		opts = append(opts, WithHook(i.hook))
+	}
+	if i.limits != nil {
+		opts = append(opts, withLimits(i.limits))
+	}
+	if i.caps != nil {
+		opts = append(opts, withCapabilities(i.caps))
+	}
+	module, err := i.loader.Load(i.path, stmt.Path.Literal.(string), opts...)
+	var rerr *RuntimeError
+	if errors.As(err, &rerr) || IsLimit(err) {
+		// the module itself failed; its error already names the file and line, or is the importer's own limit
+		return nil, err
+	}
+	if err != nil {
+		return nil, &RuntimeError{token: stmt.Path, message: err.Error(), err: err}
+	}
+
+	if len(stmt.Names) == 0 {
+		i.env.Define(stmt.Alias.Lexeme, module)
+		return nil, nil
+	}
+	for _, name := range stmt.Names {
+		v, ok := module.Get(name.Lexeme)
+		if !ok {
+			return nil, NewRuntimeError(name, fmt.Sprintf("%v does not export %v", module, name.Lexeme))
+		}
+		i.env.Define(name.Lexeme, v)
+	}
+	return nil, nil
+}
+
+func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) (any, error) {
+	if err := i.alloc(functionSize + bindingSize + len(stmt.Name.Lexeme)); err != nil {
+		return nil, err
+	}
+	i.env.Define(stmt.Name.Lexeme, &Function{Declaration: stmt, closure: i.env})
+	return nil, nil
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) (any, error) {
+	if err := i.alloc(functionSize); err != nil {
+		return nil, err
+	}
+	decl := &ast.Function{Keyword: expr.Keyword, Params: expr.Params, Body: expr.Body, Generator: expr.Generator}
+	return &Function{Declaration: decl, closure: i.env}, nil
+}
+
+func (i *Interpreter) VisitReturnStmt(stmt *ast.Return) (any, error) {
+	var value any
+	if stmt.Value != nil {
+		var err error
+		value, err = i.Evaluate(stmt.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, &returnValue{value: value}
+}
+
 func checkNumberOperands(left, right any) (float64, float64, bool) {
 	if left, ok := left.(float64); ok {
 		if right, ok := right.(float64); ok {
@@ -156,7 +393,11 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (any, error) {
 			return left + right, nil
 		}
 		if left, ok := left.(string); ok {
-			return left + fmt.Sprintf("%v", right), nil
+			right := fmt.Sprintf("%v", right)
+			if err := i.alloc(len(left) + len(right)); err != nil {
+				return nil, err
+			}
+			return left + right, nil
 		}
 	case token.MINUS:
 		if left, right, ok := checkNumberOperands(left, right); ok {
@@ -210,7 +451,7 @@ func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) (any, error) {
 			return -right, nil
 		}
 	case token.BANG:
-		return !isTruthy(right), nil
+		return !IsTruthy(right), nil
 	}
 	return nil, NewRuntimeError(expr.Operator, fmt.Sprintf("unary operator %v not supported for type %T", expr.Operator.Type, right))
 }
@@ -231,6 +472,9 @@ func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) (any, error) {
 	if ok := i.env.Assign(expr.Name.Lexeme, value); !ok {
 		return nil, &RuntimeError{token: expr.Name, message: "undefined variable " + expr.Name.Lexeme}
 	}
+	if i.trace != nil {
+		i.tracef(expr.Name.Line, "  %v = %v", expr.Name.Lexeme, traceValue(value))
+	}
 	return value, nil
 }
 
@@ -239,17 +483,78 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	if expr.Operator.Type == token.OR && isTruthy(left) {
+	if expr.Operator.Type == token.OR && IsTruthy(left) {
+		i.branch(expr, false)
 		return left, nil
 	}
-	if expr.Operator.Type == token.AND && !isTruthy(left) {
+	if expr.Operator.Type == token.AND && !IsTruthy(left) {
+		i.branch(expr, false)
 		return left, nil
 	}
 
+	i.branch(expr, true)
 	return i.Evaluate(expr.Right)
 }
 
-func isTruthy(v any) bool {
+func (i *Interpreter) VisitCallExpr(expr *ast.Call) (any, error) {
+	callee, err := i.Evaluate(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, len(expr.Arguments))
+	for n, arg := range expr.Arguments {
+		args[n], err = i.Evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	callable, ok := callee.(Callable)
+	if !ok {
+		return nil, NewRuntimeError(expr.Paren, "can only call functions")
+	}
+	if arity := callable.Arity(); arity >= 0 && len(args) != arity {
+		return nil, NewRuntimeError(expr.Paren, fmt.Sprintf("expected %v arguments but got %v", callable.Arity(), len(args)))
+	}
+	if err := i.call(expr.Paren); err != nil {
+		return nil, err
+	}
+	defer i.ret()
+	rslt, err := callable.Call(i, args)
+	var rerr *RuntimeError
+	if err != nil && !errors.As(err, &rerr) && !IsLimit(err) {
+		return nil, &RuntimeError{token: expr.Paren, message: err.Error(), err: err}
+	}
+	return rslt, err
+}
+
+func (i *Interpreter) branch(node ast.Node, taken bool) {
+	if h, ok := i.hook.(BranchHook); ok {
+		h.Branch(node, taken)
+	}
+}
+
+func (i *Interpreter) VisitGetExpr(expr *ast.Get) (any, error) {
+	object, err := i.Evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	properties, ok := object.(interface{ Get(name string) (any, bool) })
+	if !ok {
+		return nil, NewRuntimeError(expr.Name, "only modules, generators and selections have properties")
+	}
+	v, ok := properties.Get(expr.Name.Lexeme)
+	if _, isModule := object.(*Module); !ok && isModule {
+		return nil, NewRuntimeError(expr.Name, fmt.Sprintf("%v does not export %v", object, expr.Name.Lexeme))
+	}
+	if !ok {
+		return nil, NewRuntimeError(expr.Name, fmt.Sprintf("%v has no property %v", object, expr.Name.Lexeme))
+	}
+	return v, nil
+}
+
+// IsTruthy reports whether v counts as true in a condition: everything except nil and false does.
+func IsTruthy(v any) bool {
 	switch v := v.(type) {
 	case nil:
 		return false
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// A for statement iterates over strings, one character at a time, ranges and generators, and over any value a native
+// function returns that implements Iterable.
+//
+// Lists, maps and classes, and with them the protocol of classes defining iterator() and next(), are not iterated
+// over: the language has none of them yet. Iterable is the extension point they are to implement once it does.
+
+// Iterator produces the values of an iteration, one for each call of Next, which returns false once there are no
+// more.
+type Iterator interface {
+	Next() (any, bool, error)
+}
+
+// Iterable is a value a for statement can iterate over. Each loop over it calls Iterate for an Iterator of its own.
+type Iterable interface {
+	Iterate() Iterator
+}
+
+// Range is the sequence of numbers returned by range(start, stop, step): start, start + step, and so on, up to but
+// not including stop. All three are finite.
+type Range struct {
+	Start, Stop, Step float64
+}
+
+func (r *Range) Iterate() Iterator {
+	return &rangeIterator{r: r}
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("<range %v %v %v>", r.Start, r.Stop, r.Step)
+}
+
+type rangeIterator struct {
+	r *Range
+	n int
+}
+
+func (it *rangeIterator) Next() (any, bool, error) {
+	// multiplying, rather than adding step each time, keeps fractional steps from drifting
+	v := it.r.Start + float64(it.n)*it.r.Step
+	if it.r.Step > 0 && v >= it.r.Stop || it.r.Step < 0 && v <= it.r.Stop {
+		return nil, false, nil
+	}
+	it.n++
+	return v, true, nil
+}
+
+// stringIterator produces the characters of a string, each as a string of its own.
+type stringIterator struct {
+	i *Interpreter
+	s string
+}
+
+func (it *stringIterator) Next() (any, bool, error) {
+	if it.s == "" {
+		return nil, false, nil
+	}
+	_, size := utf8.DecodeRuneInString(it.s)
+	c := it.s[:size]
+	it.s = it.s[size:]
+	if err := it.i.alloc(len(c)); err != nil {
+		return nil, false, err
+	}
+	return c, true, nil
+}
+
+// iterate returns an iterator over the values of v, if it can be iterated over.
+func (i *Interpreter) iterate(v any) (Iterator, bool) {
+	switch v := v.(type) {
+	case string:
+		return &stringIterator{i: i, s: v}, true
+	case *Generator:
+		return &generatorIterator{g: v, caller: i}, true
+	case Iterable:
+		return v.Iterate(), true
+	}
+	return nil, false
+}
+
+func (i *Interpreter) VisitForStmt(stmt *ast.For) (any, error) {
+	iterable, err := i.Evaluate(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	it, ok := i.iterate(iterable)
+	if !ok {
+		return nil, NewRuntimeError(stmt.Keyword, "can only iterate over strings, ranges and generators")
+	}
+
+	var rslt any
+	for {
+		v, ok, err := it.Next()
+		if err != nil {
+			var rerr *RuntimeError
+			if !errors.As(err, &rerr) && !IsLimit(err) {
+				err = &RuntimeError{token: stmt.Keyword, message: err.Error(), err: err}
+			}
+			return nil, err
+		}
+		i.branch(stmt, ok)
+		if !ok {
+			break
+		}
+		if err := i.alloc(environmentSize + bindingSize + len(stmt.Name.Lexeme)); err != nil {
+			return nil, err
+		}
+		// each iteration has a scope of its own, so closures made in the body keep the value they saw
+		enclosing := i.env
+		i.env = enclosing.Wrap()
+		i.env.Define(stmt.Name.Lexeme, v)
+		rslt, err = i.execute(stmt.Body)
+		i.env = enclosing
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rslt, nil
+}
+
+func numberArg(native string, args []any, n int) (float64, error) {
+	v, ok := args[n].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%v: argument %v must be a number", native, n+1)
+	}
+	return v, nil
+}
+
+var iterationNatives = []*Native{
+	// range(stop), range(start, stop) or range(start, stop, step), counting from 0 and by 1 unless told otherwise
+	{Name: "range", Params: -1, Fn: func(i *Interpreter, args []any) (any, error) {
+		if len(args) < 1 || len(args) > 3 {
+			return nil, fmt.Errorf("range: expected 1 to 3 arguments but got %v", len(args))
+		}
+		r := &Range{Step: 1}
+		bounds := []*float64{&r.Stop}
+		switch len(args) {
+		case 2:
+			bounds = []*float64{&r.Start, &r.Stop}
+		case 3:
+			bounds = []*float64{&r.Start, &r.Stop, &r.Step}
+		}
+		for n, b := range bounds {
+			v, err := numberArg("range", args, n)
+			if err != nil {
+				return nil, err
+			}
+			// a NaN or infinite bound or step would never reach stop
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("range: argument %v must be finite", n+1)
+			}
+			*b = v
+		}
+		if r.Step == 0 {
+			return nil, errors.New("range: step must not be 0")
+		}
+		return r, nil
+	}},
+}
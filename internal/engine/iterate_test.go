@@ -0,0 +1,95 @@
+package engine_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+)
+
+// letters is an Iterable defined in Go, as a native function might return.
+type letters string
+
+func (l letters) Iterate() engine.Iterator {
+	return &lettersIterator{s: string(l)}
+}
+
+type lettersIterator struct{ s string }
+
+func (it *lettersIterator) Next() (any, bool, error) {
+	if it.s == "" {
+		return nil, false, nil
+	}
+	c := strings.ToUpper(it.s[:1])
+	it.s = it.s[1:]
+	return c, true, nil
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"string", `for (c in "héllo") print c;`, "h\né\nl\nl\no\n"},
+		{"empty string", `for (c in "") print c; print "done";`, "done\n"},
+		{"range stop", "for (x in range(3)) print x;", "0\n1\n2\n"},
+		{"range start stop", "for (x in range(2, 5)) print x;", "2\n3\n4\n"},
+		{"range step", "for (x in range(0, 10, 4)) print x;", "0\n4\n8\n"},
+		{"range down", "for (x in range(3, 0, -1)) print x;", "3\n2\n1\n"},
+		{"range fraction", "for (x in range(0, 1, 0.25)) print x;", "0\n0.25\n0.5\n0.75\n"},
+		{"range empty", `for (x in range(5, 0)) print x; print "done";`, "done\n"},
+		{"range again", "var r = range(2); for (x in r) print x; for (x in r) print x;", "0\n1\n0\n1\n"},
+		{"generator resumes", `
+			fun g() { yield 1; yield 2; yield 3; }
+			var it = g();
+			print it.next();
+			for (x in it) print x;
+			for (x in it) print x;`, "1\n2\n3\n"},
+		{"sum", "var sum = 0; for (x in range(101)) sum = sum + x; print sum;", "5050\n"},
+		{"scope", `var x = "outer"; for (x in range(1)) print x; print x;`, "0\nouter\n"},
+		{"native iterable", "for (c in letters()) print c;", "A\nB\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			native := &engine.Native{Name: "letters", Fn: func(i *engine.Interpreter, args []any) (any, error) {
+				return letters("ab"), nil
+			}}
+			i := engine.NewInterpreter(engine.WithStdout(&out), engine.WithNative(native))
+			if _, err := i.Interpret(mustParse(t, tt.source)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"range();", "expected 1 to 3 arguments but got 0"},
+		{"range(1, 2, 3, 4);", "expected 1 to 3 arguments but got 4"},
+		{`range("3");`, "argument 1 must be a number"},
+		{"range(0, 10, 0);", "step must not be 0"},
+		{"range(0, 10, 0/0);", "argument 3 must be finite"},
+		{"range(0, 1/0);", "argument 2 must be finite"},
+		{"range(-1/0, 0);", "argument 1 must be finite"},
+		{"range(0/0);", "argument 1 must be finite"},
+	}
+	for _, tt := range tests {
+		i := engine.NewInterpreter(engine.WithStdout(io.Discard))
+		_, err := i.Interpret(mustParse(t, tt.source))
+		var runtimeErr *engine.RuntimeError
+		if !errors.As(err, &runtimeErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: error = %v, want a runtime error containing %q", tt.source, err, tt.want)
+		}
+	}
+}
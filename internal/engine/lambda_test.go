@@ -0,0 +1,76 @@
+package engine_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func TestLambdas(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"fun", "var add = fun (a, b) { return a + b; }; print add(1, 2);", "3\n"},
+		{"arrow", "var double = (a) => a * 2; print double(21);", "42\n"},
+		{"arrow without parameters", `var hi = () => "hi"; print hi();`, "hi\n"},
+		{"arrow with parameters", "print ((a, b, c) => a + b * c)(1, 2, 3);", "7\n"},
+		{"arrow with block", "var square = (n) => { var m = n * n; return m; }; print square(5);", "25\n"},
+		{"callback", "fun apply(f, x) { return f(x); } print apply((x) => x + 1, 41);", "42\n"},
+		{"immediately called", "fun (x) { print x; }(7);", "7\n"},
+		{"closure", "fun adder(n) { return (x) => x + n; } var add2 = adder(2); print add2(3);", "5\n"},
+		{"curried", "var add = (a) => (b) => a + b; print add(1)(2);", "3\n"},
+		{"captures variables", `
+			var count = 0;
+			var inc = () => count = count + 1;
+			inc(); inc();
+			print count;`, "2\n"},
+		{"grouping", "var a = 1; print (a);", "1\n"},
+		{"string", "print (x) => x;", "<fn lambda>\n"},
+		{"generator", "var g = fun () { yield 1; yield 2; }; for (x in g()) print x;", "1\n2\n"},
+		{"spawned", "print wait(spawn((a, b) => a * b, 6, 7));", "42\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			i := engine.NewInterpreter(engine.WithStdout(&out), engine.AllowTasks(10))
+			if _, err := i.Interpret(mustParse(t, tt.source)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLambdaParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var f = fun x) {};", "Expect '(' after 'fun'."},
+		{"var f = fun (x) x;", "Expect '{' before function body."},
+		{"var f = (x, 1) => x;", "Expect ')' after expression."},
+		{"var f = (x) =>;", "Expect expression."},
+		{"(x) => { return; };", ""},
+	}
+	for _, tt := range tests {
+		tokens, err := scan.Scan(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parse.Parse(tokens)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%v: Parse() error = %v", tt.source, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%v: Parse() error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// limits bounds the execution of an interpreter and of the interpreters running the modules it imports and the tasks
+// it spawns, which share them. Each interpreter counts its own calls.
+type limits struct {
+	*budget
+	maxDepth int
+}
+
+// defaultMaxDepth bounds the nesting of calls when WithMaxDepth does not, so that runaway recursion stops with a
+// runtime error rather than overflowing the Go stack.
+const defaultMaxDepth = 10000
+
+// budget is what a program and all its tasks may spend together. It is shared between goroutines.
+type budget struct {
+	ctx       context.Context
+	maxSteps  int64
+	maxMemory int64
+	timeout   time.Duration
+	deadline  atomic.Int64 // in Unix nanoseconds
+	steps     atomic.Int64
+	allocated atomic.Int64
+	running   atomic.Int64 // nested calls of Interpret, and live tasks
+}
+
+// StepLimitError is returned when a program executes more statements than WithMaxSteps allows.
+type StepLimitError struct {
+	Limit int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("step limit of %v statements exceeded", e.Limit)
+}
+
+// DepthLimitError is returned when calls nest deeper than WithMaxDepth allows.
+type DepthLimitError struct {
+	Limit int
+}
+
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("call depth limit of %v exceeded", e.Limit)
+}
+
+// TimeoutError is returned when a program runs for longer than WithTimeout allows.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout of %v exceeded", e.Timeout)
+}
+
+// CanceledError is returned when the context of WithContext is done. It wraps the context's error.
+type CanceledError struct {
+	err error
+}
+
+func (e *CanceledError) Error() string {
+	return "interpretation canceled: " + e.err.Error()
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.err
+}
+
+// ResourceExhaustedError is returned when a program allocates more memory than WithMaxMemory allows.
+type ResourceExhaustedError struct {
+	Resource string
+	Limit    int
+}
+
+func (e *ResourceExhaustedError) Error() string {
+	return fmt.Sprintf("resource exhausted: %v limit of %v bytes exceeded", e.Resource, e.Limit)
+}
+
+func (e *StepLimitError) limit()         {}
+func (e *DepthLimitError) limit()        {}
+func (e *TimeoutError) limit()           {}
+func (e *CanceledError) limit()          {}
+func (e *ResourceExhaustedError) limit() {}
+
+// IsLimit reports whether err, or an error it wraps, stopped a program for exceeding one of its limits.
+func IsLimit(err error) bool {
+	var l interface{ limit() }
+	return errors.As(err, &l)
+}
+
+// WithContext stops the program, with a CanceledError, once ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(i *Interpreter) {
+		i.limit().ctx = ctx
+	}
+}
+
+// WithMaxSteps stops the program, with a StepLimitError, when it is about to execute more than n statements over the
+// life of the interpreter, counting those of the modules it imports and of the tasks it spawns.
+func WithMaxSteps(n int) Option {
+	return func(i *Interpreter) {
+		i.limit().maxSteps = int64(n)
+	}
+}
+
+// WithMaxDepth stops the program, with a DepthLimitError, when it is about to nest more than n calls. Without it, calls
+// nest at most 10000 deep, and the program stops with a "Stack overflow." runtime error beyond that.
+func WithMaxDepth(n int) Option {
+	return func(i *Interpreter) {
+		i.limit().maxDepth = n
+	}
+}
+
+// WithTimeout stops the program, with a TimeoutError, once d has passed since Interpret was called.
+func WithTimeout(d time.Duration) Option {
+	return func(i *Interpreter) {
+		i.limit().timeout = d
+	}
+}
+
+// WithMaxMemory stops the program, with a ResourceExhaustedError, when it is about to allocate more than n bytes of
+// strings and environments over the life of the interpreter, counting those of the modules it imports and of the
+// tasks it spawns. The limit bounds what is allocated in total rather than what is live at once, since the Go garbage
+// collector, not the interpreter, frees values; sizes are estimates of what each allocation costs.
+func WithMaxMemory(n int) Option {
+	return func(i *Interpreter) {
+		i.limit().maxMemory = int64(n)
+	}
+}
+
+// withLimits shares the limits of an importing interpreter with the interpreter of a module.
+func withLimits(l *limits) Option {
+	return func(i *Interpreter) {
+		i.limits = l
+	}
+}
+
+// withCalls starts the interpreter of a module at the call depth of the import running it.
+func withCalls(n int) Option {
+	return func(i *Interpreter) {
+		i.calls = n
+	}
+}
+
+func (i *Interpreter) limit() *limits {
+	if i.limits == nil {
+		i.limits = &limits{budget: &budget{}}
+	}
+	return i.limits
+}
+
+// start begins a call of Interpret or a task, starting the clock of the timeout if nothing else is running.
+func (b *budget) start() {
+	if b.running.Add(1) == 1 && b.timeout > 0 {
+		b.deadline.Store(time.Now().Add(b.timeout).UnixNano())
+	}
+}
+
+func (b *budget) stop() {
+	b.running.Add(-1)
+}
+
+func (b *budget) expiry() time.Time {
+	return time.Unix(0, b.deadline.Load())
+}
+
+// step counts a statement about to execute, and checks every limit except the call depth.
+func (b *budget) step() error {
+	if steps := b.steps.Add(1); b.maxSteps > 0 && steps > b.maxSteps {
+		return &StepLimitError{Limit: int(b.maxSteps)}
+	}
+	if b.timeout > 0 && time.Now().After(b.expiry()) {
+		return &TimeoutError{Timeout: b.timeout}
+	}
+	if b.ctx != nil {
+		select {
+		case <-b.ctx.Done():
+			return &CanceledError{err: b.ctx.Err()}
+		default:
+		}
+	}
+	return nil
+}
+
+// call counts a call about to start at paren, and checks the call depth.
+func (i *Interpreter) call(paren token.Token) error {
+	if l := i.limits; l != nil && l.maxDepth > 0 {
+		if i.calls >= l.maxDepth {
+			return &DepthLimitError{Limit: l.maxDepth}
+		}
+	} else if i.calls >= defaultMaxDepth {
+		return NewRuntimeError(paren, "Stack overflow.")
+	}
+	i.calls++
+	return nil
+}
+
+func (i *Interpreter) ret() {
+	i.calls--
+}
+
+// Estimated sizes of the values the interpreter allocates, besides the bytes of names and strings.
+const (
+	environmentSize = 48
+	bindingSize     = 32
+	functionSize    = 48
+)
+
+// alloc accounts for n bytes about to be allocated, and checks the memory limit.
+func (i *Interpreter) alloc(n int) error {
+	l := i.limits
+	if l == nil {
+		return nil
+	}
+	if allocated := l.allocated.Add(int64(n)); l.maxMemory > 0 && allocated > l.maxMemory {
+		return &ResourceExhaustedError{Resource: "memory", Limit: int(l.maxMemory)}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func mustParse(t *testing.T, source string) []ast.Stmt {
+	t.Helper()
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		source string
+		opt    engine.Option
+		target any
+	}{
+		{"steps", "while (true) {}", engine.WithMaxSteps(1000), new(*engine.StepLimitError)},
+		{"depth", "fun f() { f(); } f();", engine.WithMaxDepth(100), new(*engine.DepthLimitError)},
+		{"timeout", "while (true) {}", engine.WithTimeout(10 * time.Millisecond), new(*engine.TimeoutError)},
+		{"context", "while (true) {}", engine.WithContext(canceled), new(*engine.CanceledError)},
+		{"memory", `var s = "x"; while (true) s = s + s;`, engine.WithMaxMemory(1 << 20), new(*engine.ResourceExhaustedError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := engine.NewInterpreter(tt.opt, engine.WithStdout(io.Discard))
+			_, err := i.Interpret(mustParse(t, tt.source))
+			if !errors.As(err, tt.target) || !engine.IsLimit(err) {
+				t.Fatalf("Interpret() error = %v, want %T", err, tt.target)
+			}
+		})
+	}
+}
+
+func TestDefaultMaxDepth(t *testing.T) {
+	i := engine.NewInterpreter(engine.WithStdout(io.Discard))
+	_, err := i.Interpret(mustParse(t, "fun f(n) { return f(n + 1); } f(0);"))
+	var rerr *engine.RuntimeError
+	if !errors.As(err, &rerr) || err.Error() != "runtime error: Stack overflow. at line 1" {
+		t.Fatalf("Interpret() error = %v, want a stack overflow", err)
+	}
+	if engine.IsLimit(err) {
+		t.Errorf("IsLimit(%v) = true, want false", err)
+	}
+}
+
+func TestCanceledWrapsContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := engine.NewInterpreter(engine.WithContext(ctx)).Interpret(mustParse(t, "print 1;"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Interpret() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestLimitsAllowFinishingPrograms(t *testing.T) {
+	source := "fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(10);"
+	i := engine.NewInterpreter(
+		engine.WithMaxSteps(10000),
+		engine.WithMaxDepth(20),
+		engine.WithTimeout(time.Minute),
+		engine.WithContext(context.Background()),
+		engine.WithStdout(io.Discard),
+	)
+	if _, err := i.Interpret(mustParse(t, source)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxMemoryStopsRunawayScripts(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"string doubling", `var s = "x"; while (true) s = s + s;`},
+		{"string appending", `var s = ""; while (true) s = s + "more text";`},
+		{"environments", "while (true) { var a = 1; { var b = 2; } }"},
+		{"closures", "fun f() { fun g() {} f(); } f();"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := engine.NewInterpreter(engine.WithMaxMemory(1<<20), engine.WithStdout(io.Discard))
+			_, err := i.Interpret(mustParse(t, tt.source))
+			var exhausted *engine.ResourceExhaustedError
+			if !errors.As(err, &exhausted) {
+				t.Fatalf("Interpret() error = %v, want a ResourceExhaustedError", err)
+			}
+			if exhausted.Resource != "memory" || exhausted.Limit != 1<<20 {
+				t.Errorf("error = %+v, want the memory limit of 1MiB", exhausted)
+			}
+		})
+	}
+}
+
+func TestLimitsApplyToModules(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.lox"), []byte(`var s = "x"; while (true) s = s + s;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	i := engine.NewInterpreter(
+		engine.WithLoader(engine.NewLoader(scan.Scan, parse.Parse, nil)),
+		engine.WithPath(filepath.Join(dir, "main.lox")),
+		engine.AllowFS(dir),
+		engine.WithMaxMemory(1<<20),
+	)
+	_, err := i.Interpret(mustParse(t, `import "lib.lox" as lib;`))
+	var exhausted *engine.ResourceExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("Interpret() error = %v, want a ResourceExhaustedError", err)
+	}
+}
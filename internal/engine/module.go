@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// Module is the runtime value of an imported file. Its exports are the top-level bindings of the file whose names
+// do not begin with an underscore.
+type Module struct {
+	Path string
+	env  *Environment
+}
+
+func (m *Module) Get(name string) (any, bool) {
+	if strings.HasPrefix(name, "_") {
+		return nil, false
+	}
+	v, ok := m.env.values[name]
+	return v, ok
+}
+
+func (m *Module) String() string {
+	return "<module " + filepath.Base(m.Path) + ">"
+}
+
+// Loader resolves, runs and caches modules. Each file is executed at most once; later imports of the same file
+// share its top-level Environment. A Loader is not safe for concurrent use, so interpreters running at the same time
+// each need their own.
+type Loader struct {
+	Scan       func(source string) ([]token.Token, error)
+	Parse      func(tokens []token.Token) ([]ast.Stmt, error)
+	SearchPath []string
+	modules    map[string]*Module
+	loading    []string
+}
+
+func NewLoader(
+	scan func(source string) ([]token.Token, error),
+	parse func(tokens []token.Token) ([]ast.Stmt, error),
+	searchPath []string,
+) *Loader {
+	return &Loader{
+		Scan:       scan,
+		Parse:      parse,
+		SearchPath: searchPath,
+		modules:    make(map[string]*Module),
+	}
+}
+
+// Resolve finds the file named by spec, first relative to the directory of the importing file and then in each
+// directory of the search path. An empty importer resolves relative to the working directory.
+func (l *Loader) Resolve(importer, spec string) (string, error) {
+	return l.resolve(importer, spec, filepath.Abs)
+}
+
+// resolve is Resolve, looking only at the candidates that allow returns the absolute form of. If the file is not
+// found, the error of the first candidate allow refused is returned.
+func (l *Loader) resolve(importer, spec string, allow func(path string) (string, error)) (string, error) {
+	var candidates []string
+	if filepath.IsAbs(spec) {
+		candidates = append(candidates, spec)
+	} else {
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+		candidates = append(candidates, filepath.Join(dir, spec))
+		for _, p := range l.SearchPath {
+			candidates = append(candidates, filepath.Join(p, spec))
+		}
+	}
+
+	var denied error
+	for _, c := range candidates {
+		abs, err := allow(c)
+		if err != nil {
+			if denied == nil {
+				denied = err
+			}
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			return abs, nil
+		}
+	}
+	if denied != nil {
+		return "", denied
+	}
+	return "", fmt.Errorf("module %q not found", spec)
+}
+
+// Load returns the module named by spec, executing it on first use with an interpreter configured by opts. Reading a
+// module needs the FS capability, and only files under the paths it allows are found.
+func (l *Loader) Load(importer, spec string, opts ...Option) (*Module, error) {
+	interpreter := NewInterpreter(opts...)
+	if interpreter.caps == nil || interpreter.caps.granted&FS == 0 {
+		return nil, &PermissionError{Native: "import", Capability: FS}
+	}
+	path, err := l.resolve(importer, spec, func(path string) (string, error) {
+		return interpreter.allowPath("import", path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := l.modules[path]; ok {
+		return m, nil
+	}
+
+	// the script that started the first import is part of the chain even though it was not loaded as a module
+	chain := l.loading
+	if len(chain) == 0 && importer != "" {
+		if root, err := filepath.Abs(importer); err == nil {
+			chain = []string{root}
+		}
+	}
+	for i, p := range chain {
+		if p == path {
+			cycle := append(append([]string{}, chain[i:]...), path)
+			return nil, fmt.Errorf("import cycle: %v", strings.Join(cycle, " -> "))
+		}
+	}
+
+	l.loading = append(chain, path)
+	defer func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}()
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := l.Scan(string(bytes))
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := l.Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	interpreter.loader, interpreter.path = l, path
+	if h, ok := interpreter.hook.(ModuleHook); ok {
+		h.LoadModule(path, stmts)
+	}
+	if _, err := interpreter.Interpret(stmts); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	m := &Module{Path: path, env: interpreter.env}
+	l.modules[path] = m
+	return m, nil
+}
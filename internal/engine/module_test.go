@@ -0,0 +1,165 @@
+package engine_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// writeFiles creates each file, at a path relative to dir, with its contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	counter := "var n = 0; fun increment() { n = n + 1; } fun count() { return n; }"
+	tests := []struct {
+		name       string
+		files      map[string]string // the script is app/main.lox
+		searchPath []string          // relative to the directory of the files
+		want       string            // the output, if the script succeeds
+		wantErr    string            // part of the error, with the directory of the files removed, if it fails
+	}{
+		{"relative to importer", map[string]string{
+			"app/main.lox":  `import "lib/a.lox" as a; print a.name;`,
+			"app/lib/a.lox": `import "b.lox" as b; var name = b.name;`,
+			"app/lib/b.lox": `var name = "lib/b";`,
+			"app/b.lox":     `var name = "b";`,
+		}, nil, "lib/b\n", ""},
+		{"parent directory", map[string]string{
+			"app/main.lox": `import "../shared.lox" as shared; print shared.name;`,
+			"shared.lox":   `var name = "shared";`,
+		}, nil, "shared\n", ""},
+		{"search path", map[string]string{
+			"app/main.lox": `import "m.lox" as m; print m.name;`,
+			"two/m.lox":    `var name = "two";`,
+		}, []string{"one", "two"}, "two\n", ""},
+		{"search path in order", map[string]string{
+			"app/main.lox": `import "m.lox" as m; print m.name;`,
+			"one/m.lox":    `var name = "one";`,
+			"two/m.lox":    `var name = "two";`,
+		}, []string{"one", "two"}, "one\n", ""},
+		{"importer before search path", map[string]string{
+			"app/main.lox": `import "m.lox" as m; print m.name;`,
+			"app/m.lox":    `var name = "app";`,
+			"one/m.lox":    `var name = "one";`,
+		}, []string{"one"}, "app\n", ""},
+		{"not found", map[string]string{
+			"app/main.lox": `import "m.lox" as m;`,
+		}, []string{"one"}, "", `module "m.lox" not found`},
+		{"cached", map[string]string{
+			"app/main.lox": `import "counter.lox" as a; import "./counter.lox" as b; import "user.lox" as user;
+				a.increment(); b.increment(); user.increment(); print a.count();`,
+			"app/counter.lox": `print "loaded"; ` + counter,
+			"app/user.lox":    `import "counter.lox" as c; var increment = c.increment;`,
+		}, nil, "loaded\n3\n", ""},
+		{"cycle", map[string]string{
+			"app/main.lox": `import "a.lox" as a;`,
+			"app/a.lox":    `import "b.lox" as b;`,
+			"app/b.lox":    `import "a.lox" as a;`,
+		}, nil, "", "import cycle: /app/a.lox -> /app/b.lox -> /app/a.lox"},
+		{"cycle through script", map[string]string{
+			"app/main.lox": `import "a.lox" as a;`,
+			"app/a.lox":    `import "main.lox" as main;`,
+		}, nil, "", "import cycle: /app/main.lox -> /app/a.lox -> /app/main.lox"},
+		{"from import", map[string]string{
+			"app/main.lox":    `from "counter.lox" import increment, count; increment(); increment(); print count();`,
+			"app/counter.lox": counter,
+		}, nil, "2\n", ""},
+		{"from import missing name", map[string]string{
+			"app/main.lox":    `from "counter.lox" import decrement;`,
+			"app/counter.lox": counter,
+		}, nil, "", "<module counter.lox> does not export decrement"},
+		{"private name", map[string]string{
+			"app/main.lox": `import "lib.lox" as lib; print lib.name(); print lib._secret;`,
+			"app/lib.lox":  `var _secret = "secret"; fun name() { return _secret; }`,
+		}, nil, "", "<module lib.lox> does not export _secret"},
+		{"from import private name", map[string]string{
+			"app/main.lox": `from "lib.lox" import _secret;`,
+			"app/lib.lox":  `var _secret = "secret";`,
+		}, nil, "", "<module lib.lox> does not export _secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFiles(t, dir, tt.files)
+			var searchPath []string
+			for _, p := range tt.searchPath {
+				searchPath = append(searchPath, filepath.Join(dir, p))
+			}
+			main := filepath.Join(dir, "app", "main.lox")
+
+			var out bytes.Buffer
+			i := engine.NewInterpreter(
+				engine.WithLoader(engine.NewLoader(scan.Scan, parse.Parse, searchPath)),
+				engine.WithPath(main),
+				engine.WithStdout(&out),
+				engine.AllowFS(dir),
+			)
+			_, err = i.Interpret(mustParse(t, tt.files["app/main.lox"]))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(strings.ReplaceAll(err.Error(), dir, ""), tt.wantErr) {
+					t.Fatalf("Interpret() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"app/m.lox": "", "lib/n.lox": ""})
+	l := engine.NewLoader(scan.Scan, parse.Parse, []string{filepath.Join(dir, "lib")})
+
+	tests := []struct {
+		importer string
+		spec     string
+		want     string
+	}{
+		{filepath.Join(dir, "app", "main.lox"), "m.lox", filepath.Join(dir, "app", "m.lox")},
+		{filepath.Join(dir, "app", "main.lox"), "n.lox", filepath.Join(dir, "lib", "n.lox")},
+		{filepath.Join(dir, "app", "main.lox"), filepath.Join(dir, "app", "m.lox"), filepath.Join(dir, "app", "m.lox")},
+		{filepath.Join(dir, "other", "main.lox"), "m.lox", ""},
+		{filepath.Join(dir, "app", "main.lox"), "../lib", ""}, // a directory
+	}
+	for _, tt := range tests {
+		got, err := l.Resolve(tt.importer, tt.spec)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Resolve(%q, %q) = %q, want an error", tt.importer, tt.spec, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q, %q) = %q, %v, want %q", tt.importer, tt.spec, got, err, tt.want)
+		}
+	}
+}
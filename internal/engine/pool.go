@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// Pool runs one program many times, concurrently, each time in a fresh interpreter with its own globals. The program
+// is parsed once and shared, read-only, by every run; interpreters are created ahead of the runs that use them, so a
+// run does not wait for one to be set up.
+//
+// A Pool is safe for concurrent use. The interpreters it creates share whatever their options share, which must
+// therefore be safe for concurrent use too; writers for print statements, for example, are better given to each run.
+type Pool struct {
+	stmts     []ast.Stmt
+	opts      []Option
+	newLoader func() *Loader
+	ready     chan *Interpreter
+}
+
+// NewPool returns a pool running stmts, keeping up to size interpreters configured by opts ready. Since a Loader may
+// not be shared, imports are enabled by newLoader, which is called for each interpreter, instead of WithLoader; a nil
+// newLoader leaves imports disabled.
+func NewPool(stmts []ast.Stmt, size int, newLoader func() *Loader, opts ...Option) *Pool {
+	p := &Pool{stmts: stmts, opts: opts, newLoader: newLoader, ready: make(chan *Interpreter, size)}
+	for range size {
+		p.ready <- p.build()
+	}
+	return p
+}
+
+func (p *Pool) build() *Interpreter {
+	opts := p.opts
+	if p.newLoader != nil {
+		opts = append(opts[:len(opts):len(opts)], WithLoader(p.newLoader()))
+	}
+	return NewInterpreter(opts...)
+}
+
+// Run runs the program in an interpreter configured by the pool's options and then opts, and returns the value of
+// its last statement. The interpreter is discarded afterwards, and a fresh one made ready in its place.
+func (p *Pool) Run(opts ...Option) (any, error) {
+	var i *Interpreter
+	select {
+	case i = <-p.ready:
+	default:
+		i = p.build()
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	defer p.refill()
+	return i.Interpret(p.stmts)
+}
+
+func (p *Pool) refill() {
+	if len(p.ready) == cap(p.ready) {
+		return
+	}
+	select {
+	case p.ready <- p.build():
+	default:
+	}
+}
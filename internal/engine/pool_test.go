@@ -0,0 +1,91 @@
+package engine_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// TestPoolParallel runs many scripts at once, each through its own pool, and checks that every run sees only its own
+// globals. Run it with -race.
+func TestPoolParallel(t *testing.T) {
+	dir := t.TempDir()
+	module := "var count = 0; fun increment() { count = count + 1; return count; }"
+	if err := os.WriteFile(filepath.Join(dir, "counter.lox"), []byte(module), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	scripts := []struct {
+		source string
+		want   string
+	}{
+		{"var total = 0; var i = 0; while (i < 100) { total = total + i; i = i + 1; } print total;", "4950\n"},
+		{"fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(15);", "610\n"},
+		{`fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+		  var c = counter(); c(); c(); print c();`, "3\n"},
+		{`var s = ""; var i = 0; while (i < 5) { s = s + "ab"; i = i + 1; } print s;`, "ababababab\n"},
+		{`import "counter.lox" as counter; counter.increment(); print counter.increment();`, "2\n"},
+		{`var x; { var x = "inner"; print x; } print x;`, "inner\n<nil>\n"},
+	}
+
+	newLoader := func() *engine.Loader { return engine.NewLoader(scan.Scan, parse.Parse, nil) }
+	var wg sync.WaitGroup
+	for n, script := range scripts {
+		stmts := mustParse(t, script.source)
+		pool := engine.NewPool(stmts, 4, newLoader, engine.WithPath(filepath.Join(dir, "main.lox")), engine.AllowClock(), engine.AllowFS(dir))
+		for run := range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var out bytes.Buffer
+				if _, err := pool.Run(engine.WithStdout(&out)); err != nil {
+					t.Errorf("script %v run %v: %v", n, run, err)
+					return
+				}
+				if out.String() != script.want {
+					t.Errorf("script %v run %v printed %q, want %q", n, run, out.String(), script.want)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// TestPoolGlobalsPerRun checks that globals set by one run are not seen by the next.
+func TestPoolGlobalsPerRun(t *testing.T) {
+	stmts := mustParse(t, `var seen = "fresh"; fun mark() { seen = "marked"; } print seen; mark();`)
+	pool := engine.NewPool(stmts, 1, nil)
+	for run := range 3 {
+		var out bytes.Buffer
+		if _, err := pool.Run(engine.WithStdout(&out)); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != "fresh\n" {
+			t.Errorf("run %v printed %q, want %q", run, out.String(), "fresh\n")
+		}
+	}
+}
+
+// TestInterpretersShareProgram runs one parsed program in many separate interpreters at once.
+func TestInterpretersShareProgram(t *testing.T) {
+	stmts := mustParse(t, "fun square(x) { return x * x; } var i = 0; while (i < 50) { i = i + 1; } square(i);")
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rslt, err := engine.NewInterpreter().Interpret(stmts)
+			if err != nil || fmt.Sprint(rslt) != "2500" {
+				t.Errorf("Interpret() = %v, %v, want 2500", rslt, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// WithTrace logs every statement the interpreter executes to w, before executing it, followed by the values of
+// expression statements, variable declarations and assignments as they are produced. Each line starts with the source
+// line and the depth of the current scope, with 0 for the global scope.
+func WithTrace(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.trace = w
+	}
+}
+
+func (i *Interpreter) tracef(line int, format string, args ...any) {
+	depth := 0
+	for env := i.env.Unwrap(); env != nil; env = env.Unwrap() {
+		depth++
+	}
+	fmt.Fprintf(i.trace, "line %v depth %v: %v\n", line, depth, fmt.Sprintf(format, args...))
+}
+
+// Describe names a statement in traces and profiles.
+func Describe(stmt ast.Stmt) string {
+	switch s := stmt.(type) {
+	case *ast.Expression:
+		return "expression"
+	case *ast.Print:
+		return "print"
+	case *ast.Var:
+		return "var " + s.Name.Lexeme
+	case *ast.Block:
+		return "block"
+	case *ast.If:
+		return "if"
+	case *ast.While:
+		return "while"
+	case *ast.Import:
+		return "import " + s.Path.Lexeme
+	case *ast.Function:
+		return "fun " + s.Name.Lexeme
+	case *ast.Return:
+		return "return"
+	case *ast.For:
+		return "for " + s.Name.Lexeme
+	case *ast.Yield:
+		return "yield"
+	}
+	return fmt.Sprintf("%T", stmt)
+}
+
+// traceValue formats a value for the trace, quoting strings so they can be told apart from other values.
+func traceValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(v)
+}
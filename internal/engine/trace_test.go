@@ -0,0 +1,52 @@
+package engine_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+)
+
+func TestTrace(t *testing.T) {
+	source := `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = add(1, 2);
+x = x * 2;
+if (x > 1) {
+  print "big";
+}
+for (n in range(1)) add("a", "b");
+`
+	want := `line 1 depth 0: fun add
+line 5 depth 0: var x
+line 1 depth 1: block
+line 2 depth 2: var sum
+line 2 depth 2:   sum = 3
+line 3 depth 2: return
+line 5 depth 0:   x = 3
+line 6 depth 0: expression
+line 6 depth 0:   x = 6
+line 6 depth 0:   => 6
+line 7 depth 0: if
+line 7 depth 0: block
+line 8 depth 1: print
+line 10 depth 0: for n
+line 10 depth 1: expression
+line 1 depth 1: block
+line 2 depth 2: var sum
+line 2 depth 2:   sum = "ab"
+line 3 depth 2: return
+line 10 depth 1:   => "ab"
+`
+	var trace bytes.Buffer
+	i := engine.NewInterpreter(engine.WithTrace(&trace), engine.WithStdout(io.Discard))
+	if _, err := i.Interpret(mustParse(t, source)); err != nil {
+		t.Fatal(err)
+	}
+	if trace.String() != want {
+		t.Errorf("trace is\n%v\nwant\n%v", trace.String(), want)
+	}
+}
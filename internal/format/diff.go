@@ -0,0 +1,124 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// Diff returns a unified diff turning before into after, or an empty string if they are equal.
+func Diff(name, before, after string) string {
+	if before == after {
+		return ""
+	}
+	edits := diffLines(splitLines(before), splitLines(after))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %v\n+++ %v\n", name, name)
+	for start := 0; start < len(edits); {
+		// skip to the next change, then back up to include its leading context
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		first := max(start-diffContext, 0)
+
+		// extend the hunk until a run of unchanged lines long enough to separate it from the next change
+		end, unchanged := start, 0
+		for end < len(edits) && unchanged <= 2*diffContext {
+			if edits[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		last := end - max(unchanged-diffContext, 0)
+
+		oldStart, newStart := 1, 1
+		for _, e := range edits[:first] {
+			if e.op != '+' {
+				oldStart++
+			}
+			if e.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, e := range edits[first:last] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%v,%v +%v,%v @@\n", oldStart, oldCount, newStart, newCount)
+		for _, e := range edits[first:last] {
+			b.WriteByte(e.op)
+			b.WriteString(e.line)
+			b.WriteByte('\n')
+		}
+		start = last
+	}
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	// mark a missing final newline so that adding one shows up as a change
+	lines[len(lines)-1] += "\n\\ No newline at end of file"
+	return lines
+}
+
+// diffLines computes a shortest edit script from the longest common subsequence of a and b.
+func diffLines(a, b []string) []edit {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+	return edits
+}
@@ -0,0 +1,394 @@
+// package format prints Lox syntax trees back as canonical, indented source.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+const indent = "    "
+
+// Source formats Lox source text. It refuses to format source with scan or parse errors, since anything the scanner
+// or parser could not understand would be lost.
+func Source(source string) (string, error) {
+	scanner := scan.NewScanner(source)
+	tokens := scanner.ScanTokens()
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		return "", err
+	}
+	code := make(map[int]bool)
+	for _, t := range tokens {
+		code[t.Line] = true
+	}
+	return format(stmts, scanner.Comments, code), nil
+}
+
+// Format prints stmts as Lox source, placing each comment before the statement that follows it or, when it shares a
+// line with the end of a statement, after that statement. Single blank lines between statements are preserved.
+func Format(stmts []ast.Stmt, comments []token.Token) string {
+	return format(stmts, comments, nil)
+}
+
+// format is Format, given the source lines that hold tokens other than comments.
+func format(stmts []ast.Stmt, comments []token.Token, code map[int]bool) string {
+	f := &formatter{comments: comments, code: code, next: math.MaxInt, fresh: true}
+	f.stmtList(stmts, math.MaxInt)
+	f.leading(math.MaxInt)
+	return f.b.String()
+}
+
+type formatter struct {
+	b        strings.Builder
+	comments []token.Token
+	code     map[int]bool // source lines that hold code, if known
+	depth    int
+	lastLine int  // last source line written
+	next     int  // line on which the following statement starts
+	fresh    bool // nothing written yet at this depth, so no blank line is needed
+}
+
+func (f *formatter) stmtList(stmts []ast.Stmt, end int) {
+	outer := f.next
+	defer func() {
+		f.next = outer
+	}()
+
+	for i, s := range stmts {
+		f.next = end
+		if i+1 < len(stmts) {
+			if line := ast.StmtLine(stmts[i+1]); line > 0 {
+				f.next = line
+			}
+		}
+		s.Accept(f)
+	}
+}
+
+// open starts a new output line for a construct that begins on source line, first writing any comments before it.
+// A line of 0, for a construct whose position is unknown, forgets the last line written, so that no blank line is
+// guessed between it and the next construct.
+func (f *formatter) open(line int) {
+	f.leading(line)
+	if line > f.lastLine+1 && f.lastLine > 0 && !f.fresh {
+		f.b.WriteString("\n")
+	}
+	f.fresh = false
+	f.b.WriteString(strings.Repeat(indent, f.depth))
+	if line == 0 {
+		f.lastLine = 0
+	}
+	f.lastLine = max(f.lastLine, line)
+}
+
+// close ends the current output line for a construct that ends on source line.
+func (f *formatter) close(line int) {
+	f.trailing(line, f.next)
+	f.b.WriteString("\n")
+	f.lastLine = max(f.lastLine, line)
+}
+
+// leading writes, each on its own line, the comments that appear before source line.
+func (f *formatter) leading(line int) {
+	for len(f.comments) > 0 && f.comments[0].Line < line {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+		if c.Line > f.lastLine+1 && f.lastLine > 0 && !f.fresh {
+			f.b.WriteString("\n")
+		}
+		f.fresh = false
+		f.b.WriteString(strings.Repeat(indent, f.depth))
+		f.b.WriteString(c.Lexeme)
+		f.b.WriteString("\n")
+		f.lastLine = c.Line
+	}
+}
+
+// trailing appends the comments on source line to the current output line, unless another construct starting on
+// the same line (at next) will claim them. Literals carry no position, so a construct may end on a later line than
+// line says; a comment after code on a line before next follows the end of it too.
+func (f *formatter) trailing(line, next int) {
+	if line <= 0 || next <= line {
+		return
+	}
+	for len(f.comments) > 0 {
+		c := f.comments[0]
+		if c.Line != line && (c.Line < line || c.Line >= next || !f.code[c.Line]) {
+			return
+		}
+		f.b.WriteString(" ")
+		f.b.WriteString(c.Lexeme)
+		f.comments = f.comments[1:]
+		f.lastLine = max(f.lastLine, c.Line)
+	}
+}
+
+// block writes a braced block on the current output line, leaving the line open after the closing brace.
+func (f *formatter) block(block *ast.Block) {
+	f.b.WriteString("{")
+	if len(block.Statements) == 0 && (len(f.comments) == 0 || f.comments[0].Line >= block.RightBrace.Line) {
+		f.b.WriteString("}")
+		return
+	}
+
+	first := block.RightBrace.Line
+	if len(block.Statements) > 0 {
+		if line := ast.StmtLine(block.Statements[0]); line > 0 {
+			first = line
+		}
+	}
+	f.trailing(block.LeftBrace.Line, first)
+	f.b.WriteString("\n")
+	f.lastLine = max(f.lastLine, block.LeftBrace.Line)
+	f.fresh = true
+
+	f.depth++
+	f.stmtList(block.Statements, block.RightBrace.Line)
+	f.leading(block.RightBrace.Line)
+	f.depth--
+
+	f.b.WriteString(strings.Repeat(indent, f.depth))
+	f.b.WriteString("}")
+	f.fresh = false
+	f.lastLine = max(f.lastLine, block.RightBrace.Line)
+}
+
+// body writes the body of an if or while statement after its header, and ends the line.
+func (f *formatter) body(stmt ast.Stmt) {
+	if block, ok := stmt.(*ast.Block); ok {
+		f.b.WriteString(" ")
+		f.block(block)
+		f.close(block.RightBrace.Line)
+		return
+	}
+	f.b.WriteString("\n")
+	f.depth++
+	// the body follows its header directly, wherever it started in the source
+	f.fresh = true
+	stmt.Accept(f)
+	f.depth--
+}
+
+func (f *formatter) expr(expr ast.Expr) string {
+	v, _ := expr.Accept(f)
+	return v.(string)
+}
+
+func (f *formatter) VisitExpressionStmt(stmt *ast.Expression) (any, error) {
+	line := ast.StmtLine(stmt)
+	f.open(line)
+	f.b.WriteString(f.expr(stmt.Expression) + ";")
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitPrintStmt(stmt *ast.Print) (any, error) {
+	f.open(stmt.Keyword.Line)
+	f.b.WriteString("print " + f.expr(stmt.Expression) + ";")
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitVarStmt(stmt *ast.Var) (any, error) {
+	f.open(stmt.Name.Line)
+	f.b.WriteString("var " + stmt.Name.Lexeme)
+	if stmt.Expression != nil {
+		f.b.WriteString(" = " + f.expr(stmt.Expression))
+	}
+	f.b.WriteString(";")
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitBlockStmt(stmt *ast.Block) (any, error) {
+	f.open(stmt.LeftBrace.Line)
+	f.block(stmt)
+	f.close(stmt.RightBrace.Line)
+	return nil, nil
+}
+
+func (f *formatter) VisitIfStmt(stmt *ast.If) (any, error) {
+	f.open(stmt.Keyword.Line)
+	f.ifStmt(stmt)
+	return nil, nil
+}
+
+// ifStmt writes an if statement, and any chain of else-if branches, starting on an already opened line.
+func (f *formatter) ifStmt(stmt *ast.If) {
+	f.b.WriteString("if (" + f.expr(stmt.Condition) + ")")
+	if stmt.ElseBranch == nil {
+		f.body(stmt.ThenBranch)
+		return
+	}
+
+	if block, ok := stmt.ThenBranch.(*ast.Block); ok {
+		f.b.WriteString(" ")
+		f.block(block)
+		f.b.WriteString(" else")
+	} else {
+		// a comment after the else branch on the same line is not the then branch's
+		outer := f.next
+		if line := ast.StmtLine(stmt.ElseBranch); line > 0 {
+			f.next = line
+		}
+		f.body(stmt.ThenBranch)
+		f.next = outer
+		f.open(0)
+		f.b.WriteString("else")
+	}
+
+	if elseIf, ok := stmt.ElseBranch.(*ast.If); ok {
+		f.b.WriteString(" ")
+		f.ifStmt(elseIf)
+		return
+	}
+	f.body(stmt.ElseBranch)
+}
+
+func (f *formatter) VisitWhileStmt(stmt *ast.While) (any, error) {
+	f.open(stmt.Keyword.Line)
+	f.b.WriteString("while (" + f.expr(stmt.Condition) + ")")
+	f.body(stmt.Body)
+	return nil, nil
+}
+
+func (f *formatter) VisitImportStmt(stmt *ast.Import) (any, error) {
+	f.open(stmt.Keyword.Line)
+	if len(stmt.Names) == 0 {
+		f.b.WriteString("import " + stmt.Path.Lexeme + " as " + stmt.Alias.Lexeme + ";")
+	} else {
+		names := make([]string, len(stmt.Names))
+		for i, name := range stmt.Names {
+			names[i] = name.Lexeme
+		}
+		f.b.WriteString("from " + stmt.Path.Lexeme + " import " + strings.Join(names, ", ") + ";")
+	}
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitFunctionStmt(stmt *ast.Function) (any, error) {
+	f.open(stmt.Keyword.Line)
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+	}
+	f.b.WriteString("fun " + stmt.Name.Lexeme + "(" + strings.Join(params, ", ") + ") ")
+	f.block(stmt.Body)
+	f.close(stmt.Body.RightBrace.Line)
+	return nil, nil
+}
+
+func (f *formatter) VisitReturnStmt(stmt *ast.Return) (any, error) {
+	f.open(stmt.Keyword.Line)
+	if stmt.Value == nil {
+		f.b.WriteString("return;")
+	} else {
+		f.b.WriteString("return " + f.expr(stmt.Value) + ";")
+	}
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitForStmt(stmt *ast.For) (any, error) {
+	f.open(stmt.Keyword.Line)
+	f.b.WriteString("for (" + stmt.Name.Lexeme + " in " + f.expr(stmt.Iterable) + ")")
+	f.body(stmt.Body)
+	return nil, nil
+}
+
+func (f *formatter) VisitYieldStmt(stmt *ast.Yield) (any, error) {
+	f.open(stmt.Keyword.Line)
+	if stmt.Value == nil {
+		f.b.WriteString("yield;")
+	} else {
+		f.b.WriteString("yield " + f.expr(stmt.Value) + ";")
+	}
+	f.close(ast.StmtEndLine(stmt))
+	return nil, nil
+}
+
+func (f *formatter) VisitBinaryExpr(expr *ast.Binary) (any, error) {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right), nil
+}
+
+func (f *formatter) VisitGroupingExpr(expr *ast.Grouping) (any, error) {
+	return "(" + f.expr(expr.Expression) + ")", nil
+}
+
+func (f *formatter) VisitLiteralExpr(expr *ast.Literal) (any, error) {
+	return Literal(expr.Value), nil
+}
+
+func (f *formatter) VisitUnaryExpr(expr *ast.Unary) (any, error) {
+	return expr.Operator.Lexeme + f.expr(expr.Right), nil
+}
+
+func (f *formatter) VisitVariableExpr(expr *ast.Variable) (any, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (f *formatter) VisitAssignExpr(expr *ast.Assign) (any, error) {
+	return expr.Name.Lexeme + " = " + f.expr(expr.Value), nil
+}
+
+func (f *formatter) VisitLogicalExpr(expr *ast.Logical) (any, error) {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right), nil
+}
+
+func (f *formatter) VisitGetExpr(expr *ast.Get) (any, error) {
+	return f.expr(expr.Object) + "." + expr.Name.Lexeme, nil
+}
+
+func (f *formatter) VisitCallExpr(expr *ast.Call) (any, error) {
+	args := make([]string, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = f.expr(arg)
+	}
+	return f.expr(expr.Callee) + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+func (f *formatter) VisitLambdaExpr(expr *ast.Lambda) (any, error) {
+	params := make([]string, len(expr.Params))
+	for i, param := range expr.Params {
+		params[i] = param.Lexeme
+	}
+	head := "fun (" + strings.Join(params, ", ") + ")"
+	if expr.Keyword.Type == token.ARROW {
+		head = "(" + strings.Join(params, ", ") + ") =>"
+		if expr.ExprBody {
+			return head + " " + f.expr(expr.Body.Statements[0].(*ast.Return).Value), nil
+		}
+	}
+
+	// the body is written at the depth of the statement it is part of, taking the comments inside it
+	body := &formatter{comments: f.comments, code: f.code, depth: f.depth, lastLine: f.lastLine, next: f.next}
+	body.block(expr.Body)
+	f.comments, f.lastLine = body.comments, body.lastLine
+	return head + " " + body.b.String(), nil
+}
+
+// Literal returns the Lox source for a literal value.
+func Literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return `"` + v + `"`
+	}
+	return fmt.Sprint(value)
+}
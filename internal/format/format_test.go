@@ -0,0 +1,40 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/format"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"indent", "if (a) { print 1; } else print 2;", "if (a) {\n    print 1;\n} else\n    print 2;\n"},
+		{"blank lines", "print 1;\n\n\n\nprint 2;", "print 1;\n\nprint 2;\n"},
+		{"leading comment", "// a\nprint 1;", "// a\nprint 1;\n"},
+		{"trailing comment", "print 1; // a\nprint 2;", "print 1; // a\nprint 2;\n"},
+		{"trailing comment after else", "if (a) print 1; else print 2; // c",
+			"if (a)\n    print 1;\nelse\n    print 2; // c\n"},
+		{"trailing comment before else", "if (a) print 1; // c\nelse print 2;",
+			"if (a)\n    print 1; // c\nelse\n    print 2;\n"},
+		{"trailing comment after multi-line statement", "print 1 +\n  2; // c\nprint 3;", "print 1 + 2; // c\nprint 3;\n"},
+		{"trailing comment after multi-line string", "print \"a\nb\"; // c", "print \"a\nb\"; // c\n"},
+		{"comments in lambda", "var f = fun () {\n  print 1; // in\n}; // out",
+			"var f = fun () {\n    print 1; // in\n}; // out\n"},
+		{"comment in empty block", "{\n  // a\n}", "{\n    // a\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format.Source(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Source() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
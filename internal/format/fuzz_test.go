@@ -0,0 +1,64 @@
+package format_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/format"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// addSeeds adds the conformance scripts and the example scripts to the seed corpus of f.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{"../../testdata/conformance/*/*.lox", "../../scripts/*.lox", "../../script.lox"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(source))
+		}
+	}
+}
+
+// FuzzFormat checks that formatted source parses to the same syntax tree as the original, and that formatting it
+// again changes nothing.
+func FuzzFormat(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		formatted, err := format.Source(source)
+		if err != nil {
+			return
+		}
+		want := parseTree(t, source)
+		if got := parseTree(t, formatted); got != want {
+			t.Fatalf("formatted source %q parses to\n%v\nwant\n%v", formatted, got, want)
+		}
+		again, err := format.Source(formatted)
+		if err != nil {
+			t.Fatalf("formatted source %q does not format: %v", formatted, err)
+		}
+		if again != formatted {
+			t.Errorf("formatting is not idempotent:\n%v", format.Diff("formatted", formatted, again))
+		}
+	})
+}
+
+func parseTree(t *testing.T, source string) string {
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatalf("source %q does not parse: %v", source, err)
+	}
+	return internal.PrintStmts(stmts)
+}
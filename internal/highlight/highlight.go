@@ -0,0 +1,166 @@
+// package highlight classifies Lox tokens for syntax highlighting and renders highlighted source.
+package highlight
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/brentellingson/go-lox/internal/scan"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+type Class int
+
+const (
+	Keyword Class = iota
+	Identifier
+	String
+	Number
+	Operator
+	Comment
+)
+
+var classNames = []string{"keyword", "identifier", "string", "number", "operator", "comment"}
+
+func (c Class) String() string {
+	return classNames[c]
+}
+
+// SemanticTokenTypes is the LSP semantic token legend for SemanticTokens, in Class order.
+var SemanticTokenTypes = []string{"keyword", "variable", "string", "number", "operator", "comment"}
+
+// Classify returns the highlighting class of a token type.
+func Classify(t token.TokenType) Class {
+	switch {
+	case t == token.IDENTIFIER:
+		return Identifier
+	case t == token.STRING:
+		return String
+	case t == token.NUMBER:
+		return Number
+	case t == token.COMMENT:
+		return Comment
+	case t >= token.TRUE && t <= token.YIELD:
+		return Keyword
+	}
+	return Operator
+}
+
+// Tokens scans source and returns its tokens and comments in source order, without the EOF token. Text the scanner
+// rejects is not part of any token.
+func Tokens(source string) []token.Token {
+	scanner := scan.NewScanner(source)
+	tokens := scanner.ScanTokens()
+	tokens = append(tokens[:len(tokens)-1:len(tokens)-1], scanner.Comments...)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].Line != tokens[j].Line {
+			return tokens[i].Line < tokens[j].Line
+		}
+		return tokens[i].Column < tokens[j].Column
+	})
+	return tokens
+}
+
+var ansiColors = map[Class]string{
+	Keyword:  "\x1b[35m",
+	String:   "\x1b[32m",
+	Number:   "\x1b[36m",
+	Operator: "\x1b[33m",
+	Comment:  "\x1b[90m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// ANSI returns source with terminal color escapes around each token. Identifiers keep the terminal's default color.
+func ANSI(source string) string {
+	plain := func(s string) string { return s }
+	return render(source, plain, func(c Class, lexeme string) string {
+		color, ok := ansiColors[c]
+		if !ok {
+			return lexeme
+		}
+		return color + lexeme + ansiReset
+	})
+}
+
+// HTML returns source as a <pre> element in which each token is a <span> whose class is the token's Class.
+func HTML(source string) string {
+	body := render(source, html.EscapeString, func(c Class, lexeme string) string {
+		return `<span class="` + c.String() + `">` + html.EscapeString(lexeme) + "</span>"
+	})
+	return `<pre class="lox">` + body + "</pre>\n"
+}
+
+// render copies source, passing the text between tokens through plain and each token through span.
+func render(source string, plain func(string) string, span func(Class, string) string) string {
+	var b strings.Builder
+	offset := 0
+	for _, t := range Tokens(source) {
+		start := Offset(source, t.Line, t.Column)
+		if start < offset {
+			continue
+		}
+		b.WriteString(plain(source[offset:start]))
+		b.WriteString(span(Classify(t.Type), t.Lexeme))
+		offset = start + len(t.Lexeme)
+	}
+	b.WriteString(plain(source[offset:]))
+	return b.String()
+}
+
+// Offset converts a 1-based line and rune column to a byte offset in source.
+func Offset(source string, line, column int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(source[offset:], '\n')
+		if i < 0 {
+			return len(source)
+		}
+		offset += i + 1
+	}
+	for c := 1; c < column && offset < len(source); c++ {
+		_, size := utf8.DecodeRuneInString(source[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// SemanticTokens encodes the tokens of source in the relative five-integer form of LSP semantic tokens, using
+// SemanticTokenTypes as the legend. Columns and lengths are counted in UTF-16 code units, as LSP positions are. Tokens
+// spanning lines are truncated at the end of their first line.
+func SemanticTokens(source string) []int {
+	var data []int
+	lines := strings.Split(source, "\n")
+	prevLine, prevStart := 1, 0
+	// the rune column and UTF-16 offset reached on line, and the rest of the line after them
+	line, column, unit, rest := 0, 1, 0, ""
+	for _, t := range Tokens(source) {
+		if t.Line != line {
+			line, column, unit, rest = t.Line, 1, 0, ""
+			if t.Line-1 < len(lines) {
+				rest = lines[t.Line-1]
+			}
+		}
+		for ; column < t.Column && rest != ""; column++ {
+			r, size := utf8.DecodeRuneInString(rest)
+			unit += utf16.RuneLen(r)
+			rest = rest[size:]
+		}
+		lexeme, _, _ := strings.Cut(t.Lexeme, "\n")
+		deltaLine := t.Line - prevLine
+		deltaStart := unit
+		if deltaLine == 0 {
+			deltaStart = unit - prevStart
+		}
+		length := 0
+		for _, r := range lexeme {
+			length += utf16.RuneLen(r)
+		}
+		data = append(data, deltaLine, deltaStart, length, int(Classify(t.Type)), 0)
+		prevLine, prevStart = t.Line, unit
+	}
+	return data
+}
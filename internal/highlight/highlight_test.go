@@ -0,0 +1,84 @@
+package highlight_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/highlight"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		typ  token.TokenType
+		want highlight.Class
+	}{
+		{token.TRUE, highlight.Keyword},
+		{token.AND, highlight.Keyword},
+		{token.FUN, highlight.Keyword},
+		{token.FROM, highlight.Keyword},
+		{token.YIELD, highlight.Keyword},
+		{token.IDENTIFIER, highlight.Identifier},
+		{token.STRING, highlight.String},
+		{token.NUMBER, highlight.Number},
+		{token.COMMENT, highlight.Comment},
+		{token.LEFT_PAREN, highlight.Operator},
+		{token.PLUS, highlight.Operator},
+		{token.LESS_EQUAL, highlight.Operator},
+		{token.ARROW, highlight.Operator},
+	}
+	for _, tt := range tests {
+		if got := highlight.Classify(tt.typ); got != tt.want {
+			t.Errorf("Classify(%v) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	source := "print a <= \"x\"; // c\nvar f = () => 1;"
+	want := "\x1b[35mprint\x1b[0m a \x1b[33m<=\x1b[0m \x1b[32m\"x\"\x1b[0m\x1b[33m;\x1b[0m \x1b[90m// c\x1b[0m\n" +
+		"\x1b[35mvar\x1b[0m f \x1b[33m=\x1b[0m \x1b[33m(\x1b[0m\x1b[33m)\x1b[0m \x1b[33m=>\x1b[0m \x1b[36m1\x1b[0m\x1b[33m;\x1b[0m"
+	if got := highlight.ANSI(source); got != want {
+		t.Errorf("ANSI() = %q, want %q", got, want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"tokens", `print "<a&b>" < 1;`, `<pre class="lox"><span class="keyword">print</span> ` +
+			`<span class="string">&#34;&lt;a&amp;b&gt;&#34;</span> <span class="operator">&lt;</span> ` +
+			`<span class="number">1</span><span class="operator">;</span></pre>` + "\n"},
+		{"rejected text", "a & <b", `<pre class="lox"><span class="identifier">a</span> &amp; ` +
+			`<span class="operator">&lt;</span><span class="identifier">b</span></pre>` + "\n"},
+	}
+	for _, tt := range tests {
+		if got := highlight.HTML(tt.source); got != tt.want {
+			t.Errorf("%v: HTML() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	source := "print \"é😀\"; var x;\nprint \"é\" + x; // ü"
+	want := []int{
+		0, 0, 5, 0, 0, // print
+		0, 6, 5, 2, 0, // "é😀", the emoji two UTF-16 units
+		0, 5, 1, 4, 0, // ;
+		0, 2, 3, 0, 0, // var
+		0, 4, 1, 1, 0, // x
+		0, 1, 1, 4, 0, // ;
+		1, 0, 5, 0, 0, // print
+		0, 6, 3, 2, 0, // "é"
+		0, 4, 1, 4, 0, // +
+		0, 2, 1, 1, 0, // x
+		0, 1, 1, 4, 0, // ;
+		0, 2, 4, 5, 0, // // ü
+	}
+	if got := highlight.SemanticTokens(source); !reflect.DeepEqual(got, want) {
+		t.Errorf("SemanticTokens() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+// package lint reports Lox code that parses but is almost certainly a mistake.
+//
+// Each Diagnostic names the rule that produced it. A comment "// lox:ignore" on the line of a diagnostic, or alone on
+// the line before it, suppresses it; "// lox:ignore rule1, rule2" suppresses only the named rules.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/optimize"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+const (
+	UnusedVariable       = "unused-variable"
+	ShadowedVariable     = "shadowed-variable"
+	UndeclaredAssignment = "undeclared-assignment"
+	ConstantCondition    = "constant-condition"
+	SelfAssignment       = "self-assignment"
+	UnreachableCode      = "unreachable-code"
+)
+
+const ignoreDirective = "// lox:ignore"
+
+type Diagnostic struct {
+	Rule    string
+	Line    int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %v: %v [%v]", d.Line, d.Message, d.Rule)
+}
+
+// Source scans, parses and lints Lox source text.
+func Source(source string) ([]Diagnostic, error) {
+	scanner := scan.NewScanner(source)
+	tokens := scanner.ScanTokens()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(stmts, scanner.Comments), nil
+}
+
+// Lint checks stmts against every rule and returns the diagnostics not suppressed by comments, ordered by line.
+func Lint(stmts []ast.Stmt, comments []token.Token) []Diagnostic {
+	var all []Diagnostic
+	// the builtins are declared around the global scope, as the interpreter defines them before the program runs
+	builtins := &scope{bindings: make(map[string]*binding)}
+	for _, name := range engine.Builtins() {
+		builtins.bindings[name] = &binding{name: token.Token{Lexeme: name}, builtin: true}
+	}
+	l := &linter{scope: &scope{parent: builtins, global: true, bindings: make(map[string]*binding)}, diags: &all}
+	ast.Walk(l, stmts)
+	// bodies of global functions run after every global is declared
+	for len(l.scope.deferred) > 0 {
+		f := l.scope.deferred[0]
+		l.scope.deferred = l.scope.deferred[1:]
+		f()
+	}
+
+	// a directive on a line of its own applies to the line after it
+	stmtLines := make(map[int]bool)
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Stmt); ok {
+			stmtLines[ast.StmtLine(stmt)] = true
+		}
+		return true
+	})
+	var directives []token.Token
+	for _, c := range comments {
+		if strings.HasPrefix(c.Lexeme, ignoreDirective) {
+			if !stmtLines[c.Line] {
+				c.Line++
+			}
+			directives = append(directives, c)
+		}
+	}
+
+	var diags []Diagnostic
+	for _, d := range all {
+		if !suppressed(d, directives) {
+			diags = append(diags, d)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Line < diags[j].Line
+	})
+	return diags
+}
+
+func suppressed(d Diagnostic, directives []token.Token) bool {
+	for _, c := range directives {
+		if c.Line != d.Line {
+			continue
+		}
+		rules := strings.TrimSpace(strings.TrimPrefix(c.Lexeme, ignoreDirective))
+		if rules == "" {
+			return true
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			if strings.TrimSpace(rule) == d.Rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type binding struct {
+	name    token.Token
+	used    bool
+	builtin bool
+}
+
+type scope struct {
+	parent   *scope
+	global   bool
+	bindings map[string]*binding
+	order    []*binding
+	deferred []func() // function bodies to check once the global scope is complete
+}
+
+func (s *scope) declare(name token.Token) {
+	b := &binding{name: name}
+	s.bindings[name.Lexeme] = b
+	s.order = append(s.order, b)
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.parent {
+		if b, ok := s.bindings[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type linter struct {
+	scope *scope
+	diags *[]Diagnostic
+}
+
+func (l *linter) report(rule string, line int, format string, args ...any) {
+	*l.diags = append(*l.diags, Diagnostic{Rule: rule, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Visit implements ast.Visitor. Blocks and declarations walk their own children, so that scopes open and close and
+// names come into scope in the order the interpreter would see them.
+func (l *linter) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.Block:
+		inner := &linter{scope: &scope{parent: l.scope, bindings: make(map[string]*binding)}, diags: l.diags}
+		inner.checkReachable(n.Statements)
+		ast.Walk(inner, n.Statements)
+		inner.endScope()
+		return nil
+	case *ast.Function:
+		l.declare(n.Name)
+		params := &scope{parent: l.scope, bindings: make(map[string]*binding)}
+		for _, param := range n.Params {
+			params.declare(param)
+		}
+		body := func() {
+			ast.Walk(&linter{scope: params, diags: l.diags}, n.Body)
+		}
+		if l.scope.global {
+			l.scope.deferred = append(l.scope.deferred, body)
+		} else {
+			body()
+		}
+		return nil
+	case *ast.Lambda:
+		params := &scope{parent: l.scope, bindings: make(map[string]*binding)}
+		for _, param := range n.Params {
+			params.declare(param)
+		}
+		body := func() {
+			ast.Walk(&linter{scope: params, diags: l.diags}, n.Body)
+		}
+		if l.scope.global {
+			l.scope.deferred = append(l.scope.deferred, body)
+		} else {
+			body()
+		}
+		return nil
+	case *ast.Var:
+		if n.Expression != nil {
+			ast.Walk(l, n.Expression)
+		}
+		l.declare(n.Name)
+		return nil
+	case *ast.For:
+		ast.Walk(l, n.Iterable)
+		inner := &linter{scope: &scope{parent: l.scope, bindings: make(map[string]*binding)}, diags: l.diags}
+		inner.declare(n.Name)
+		ast.Walk(inner, n.Body)
+		inner.endScope()
+		return nil
+	case *ast.Import:
+		if len(n.Names) == 0 {
+			l.declare(n.Alias)
+		}
+		for _, name := range n.Names {
+			l.declare(name)
+		}
+		return nil
+	case *ast.Variable:
+		if b := l.scope.lookup(n.Name.Lexeme); b != nil {
+			b.used = true
+		}
+	case *ast.Assign:
+		if v, ok := n.Value.(*ast.Variable); ok && v.Name.Lexeme == n.Name.Lexeme {
+			l.report(SelfAssignment, n.Name.Line, "self-assignment of %v", n.Name.Lexeme)
+		}
+		if l.scope.lookup(n.Name.Lexeme) == nil {
+			l.report(UndeclaredAssignment, n.Name.Line, "assignment to undeclared variable %v", n.Name.Lexeme)
+		}
+	case *ast.If:
+		l.checkCondition(n.Keyword, n.Condition)
+	case *ast.While:
+		// while (true) is the idiomatic infinite loop
+		if lit, ok := n.Condition.(*ast.Literal); !ok || lit.Value != true {
+			l.checkCondition(n.Keyword, n.Condition)
+		}
+	}
+	return l
+}
+
+func (l *linter) declare(name token.Token) {
+	if !l.scope.global {
+		if outer := l.scope.parent.lookup(name.Lexeme); outer != nil && !outer.builtin {
+			l.report(ShadowedVariable, name.Line, "declaration of %v shadows declaration at line %v", name.Lexeme, outer.name.Line)
+		}
+	}
+	l.scope.declare(name)
+}
+
+// checkReachable reports the first statement of a block that follows a return statement.
+func (l *linter) checkReachable(stmts []ast.Stmt) {
+	for i, s := range stmts {
+		if ret, ok := s.(*ast.Return); ok && i+1 < len(stmts) {
+			line := ast.StmtLine(stmts[i+1])
+			l.report(UnreachableCode, line, "unreachable code after return at line %v", ret.Keyword.Line)
+			return
+		}
+	}
+}
+
+func (l *linter) endScope() {
+	for _, b := range l.scope.order {
+		if !b.used {
+			l.report(UnusedVariable, b.name.Line, "%v declared and not used", b.name.Lexeme)
+		}
+	}
+}
+
+func (l *linter) checkCondition(keyword token.Token, condition ast.Expr) {
+	if lit, ok := optimize.Fold(condition).(*ast.Literal); ok {
+		truth := "false"
+		if engine.IsTruthy(lit.Value) {
+			truth = "true"
+		}
+		l.report(ConstantCondition, keyword.Line, "%v condition is always %v", keyword.Lexeme, truth)
+	}
+}
@@ -0,0 +1,89 @@
+package lint_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/lint"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"unused variable", "{ var a = 1; }", []string{"line 1: a declared and not used [unused-variable]"}},
+		{"used variable", "{ var a = 1; print a; }", nil},
+		{"unused global", "var a = 1;", nil},
+
+		{"shadowed variable", "var a = 1;\n{ var a = 2; print a; }",
+			[]string{"line 2: declaration of a shadows declaration at line 1 [shadowed-variable]"}},
+		{"sibling scopes", "{ var a = 1; print a; } { var a = 2; print a; }", nil},
+		{"shadowed builtin", "fun f() { var clock = 1; return clock; }", nil},
+
+		{"undeclared assignment", "x = 1;", []string{"line 1: assignment to undeclared variable x [undeclared-assignment]"}},
+		{"declared assignment", "var x; x = 1;", nil},
+		{"builtin assignment", "clock = 1;", nil},
+		{"global declared after function", "fun f() { x = 1; }\nvar x;", nil},
+		{"lambda before global", "var f = fun () { x = 1; };\nvar x;", nil},
+
+		{"constant if", "if (1 < 2) print 1;", []string{"line 1: if condition is always true [constant-condition]"}},
+		{"constant while", "while (nil) print 1;", []string{"line 1: while condition is always false [constant-condition]"}},
+		{"variable condition", "var a = 1; if (a < 2) print 1;", nil},
+		{"infinite loop", "while (true) print 1;", nil},
+
+		{"self-assignment", "var a = 1; a = a;", []string{"line 1: self-assignment of a [self-assignment]"}},
+		{"assignment of another", "var a = 1; var b = 2; a = b;", nil},
+
+		{"unreachable code", "fun f() {\n  return 1;\n  print 2;\n}",
+			[]string{"line 3: unreachable code after return at line 2 [unreachable-code]"}},
+		{"conditional return", "fun f(x) { if (x) return 1; return 2; }", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lintSource(t, tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Source() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"same line", "{ var a = 1; } // lox:ignore", nil},
+		{"line before", "// lox:ignore\n{ var a = 1; }", nil},
+		{"not the line after", "{ var a = 1; } // lox:ignore\n{ var b = 1; }",
+			[]string{"line 2: b declared and not used [unused-variable]"}},
+		{"named rule", "x = x; // lox:ignore self-assignment",
+			[]string{"line 1: assignment to undeclared variable x [undeclared-assignment]"}},
+		{"named rules", "x = x; // lox:ignore self-assignment, undeclared-assignment", nil},
+		{"other rule", "{ var a = 1; } // lox:ignore self-assignment",
+			[]string{"line 1: a declared and not used [unused-variable]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lintSource(t, tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Source() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// lintSource returns the diagnostics of source, formatted.
+func lintSource(t *testing.T, source string) []string {
+	t.Helper()
+	diags, err := lint.Source(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	return got
+}
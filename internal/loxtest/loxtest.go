@@ -0,0 +1,159 @@
+// package loxtest runs unit tests written in Lox. Test files are named *_test.lox; each top-level function in them
+// whose name starts with test_ and that takes no arguments is a test, run after the file's top-level statements. A
+// file without test functions is a single test of its top-level statements. Tests check their results with the
+// assert and assertEqual natives, which fail the test with an AssertionError.
+package loxtest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+)
+
+// AssertionError is the failure of an assert or assertEqual call.
+type AssertionError struct {
+	Message   string
+	Expected  any
+	Actual    any
+	HasValues bool // Expected and Actual were compared by assertEqual
+}
+
+func (e *AssertionError) Error() string {
+	if e.HasValues {
+		return fmt.Sprintf("%v: expected %v, got %v", e.Message, quote(e.Expected), quote(e.Actual))
+	}
+	return e.Message
+}
+
+// quote returns value as it would be written in Lox. Lox strings have no escapes, so a string is its text in quotes.
+func quote(value any) string {
+	if s, ok := value.(string); ok {
+		return `"` + s + `"`
+	}
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(value)
+}
+
+// Assert is the native assert(condition) or assert(condition, message), which fails unless condition is truthy.
+var Assert = &engine.Native{Name: "assert", Params: -1, Fn: func(i *engine.Interpreter, args []any) (any, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("assert: expected 1 or 2 arguments but got %v", len(args))
+	}
+	if engine.IsTruthy(args[0]) {
+		return nil, nil
+	}
+	message := "assertion failed"
+	if len(args) == 2 {
+		if s, ok := args[1].(string); ok && s != "" {
+			message = s
+		}
+	}
+	return nil, &AssertionError{Message: message}
+}}
+
+// AssertEqual is the native assertEqual(expected, actual), which fails unless the values are equal as by ==.
+var AssertEqual = &engine.Native{Name: "assertEqual", Params: 2, Fn: func(i *engine.Interpreter, args []any) (any, error) {
+	if args[0] == args[1] {
+		return nil, nil
+	}
+	return nil, &AssertionError{Message: "assertEqual", Expected: args[0], Actual: args[1], HasValues: true}
+}}
+
+// Result is the outcome of one test.
+type Result struct {
+	Name     string
+	Path     string
+	Line     int   // line of the failure, or 0 if the test passed or failed outside the script
+	Err      error // nil if the test passed
+	Duration time.Duration
+}
+
+// Find returns the test files among paths, walking directories for files named *_test.lox. Files named explicitly
+// are returned whatever their name.
+func Find(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), "_test.lox") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Run runs the tests in the file at path whose names match filter, or all of them if filter is nil. An error in the
+// file's top-level statements fails the file as a test named after it, and its test functions are not run.
+func Run(path string, stmts []ast.Stmt, filter *regexp.Regexp, opts ...engine.Option) []Result {
+	opts = append(opts, engine.WithPath(path), engine.WithNative(Assert), engine.WithNative(AssertEqual))
+	interpreter := engine.NewInterpreter(opts...)
+
+	var tests []string
+	for _, stmt := range stmts {
+		if f, ok := stmt.(*ast.Function); ok && strings.HasPrefix(f.Name.Lexeme, "test_") && len(f.Params) == 0 {
+			tests = append(tests, f.Name.Lexeme)
+		}
+	}
+
+	name := filepath.Base(path)
+	if len(tests) == 0 && filter != nil && !filter.MatchString(name) {
+		return nil
+	}
+	start := time.Now()
+	_, err := interpreter.Interpret(stmts)
+	if err != nil || len(tests) == 0 {
+		return []Result{result(name, path, err, time.Since(start))}
+	}
+
+	var results []Result
+	for _, test := range tests {
+		if filter != nil && !filter.MatchString(test) {
+			continue
+		}
+		fn, _ := interpreter.Environment().Get(test)
+		callable, ok := fn.(engine.Callable)
+		if !ok {
+			// the name was reassigned by the top-level statements
+			results = append(results, result(test, path, fmt.Errorf("%v is not a function", test), 0))
+			continue
+		}
+		start := time.Now()
+		_, err := callable.Call(interpreter, nil)
+		results = append(results, result(test, path, err, time.Since(start)))
+	}
+	return results
+}
+
+func result(name, path string, err error, duration time.Duration) Result {
+	rslt := Result{Name: name, Path: path, Err: err, Duration: duration}
+	var runtimeErr *engine.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		rslt.Line = runtimeErr.Line()
+	}
+	return rslt
+}
@@ -0,0 +1,55 @@
+package loxtest_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/loxtest"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string // part of the error of the test, or "" if it passes
+	}{
+		{"assert", "assert(true);", ""},
+		{"assert fails", "assert(false);", "assertion failed"},
+		{"assert message", `assert(nil, "no value");`, "no value"},
+		{"assert arguments", "assert();", "assert: expected 1 or 2 arguments but got 0"},
+		{"assertEqual", `assertEqual("a", "a");`, ""},
+		{"assertEqual fails", `assertEqual("a
+b", 1);`, `assertEqual: expected "a
+b", got 1`},
+		{"assertEqual nil", "assertEqual(nil, false);", "assertEqual: expected nil, got false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scan.Scan(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmts, err := parse.Parse(tokens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := loxtest.Run("assert_test.lox", stmts, nil, engine.WithStdout(io.Discard))
+			if len(results) != 1 {
+				t.Fatalf("Run() = %+v, want one result", results)
+			}
+			err = results[0].Err
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("test failed: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("test passed, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("test failed with %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package loxtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/format"
+)
+
+// WriteResult writes a line for a failed test, followed by its error, or when verbose, a line for a passed test. The
+// expected and actual values of a failed assertEqual on multi-line strings are shown as a diff.
+func WriteResult(w io.Writer, r Result, verbose bool) error {
+	if r.Err == nil {
+		if !verbose {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "--- PASS: %v (%.3fs)\n", r.Name, r.Duration.Seconds())
+		return err
+	}
+
+	location := r.Path
+	if r.Line > 0 {
+		location = fmt.Sprintf("%v:%v", r.Path, r.Line)
+	}
+	fmt.Fprintf(w, "--- FAIL: %v (%v)\n", r.Name, location)
+
+	var assertion *AssertionError
+	if !errors.As(r.Err, &assertion) {
+		_, err := fmt.Fprintf(w, "    %v\n", r.Err)
+		return err
+	}
+	expected, eok := assertion.Expected.(string)
+	actual, aok := assertion.Actual.(string)
+	if !assertion.HasValues || !eok || !aok || !strings.Contains(expected+actual, "\n") {
+		_, err := fmt.Fprintf(w, "    %v\n", assertion)
+		return err
+	}
+	fmt.Fprintf(w, "    %v: values differ\n", assertion.Message)
+	diff := format.Diff("value", expected, actual)
+	diff = strings.Replace(diff, "--- value\n+++ value\n", "--- expected\n+++ actual\n", 1)
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(diff, "\n"), "\n") {
+		fmt.Fprintf(w, "    %v", line)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,297 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/lint"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// symbol is a declared name: a variable, function or parameter, or an imported module or export.
+type symbol struct {
+	name     token.Token
+	kind     int
+	detail   string
+	refs     []token.Token
+	start    token.Token // the first token of the declaration
+	end      token.Token // the last token of the declaration
+	children []*symbol   // the variables and functions declared in a function
+}
+
+// occurrence is a use or declaration of a symbol in the source.
+type occurrence struct {
+	tok token.Token
+	sym *symbol
+}
+
+// document is the analysis of one open file, rebuilt whenever its text changes.
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	stmts       []ast.Stmt
+	diagnostics []Diagnostic
+	symbols     []*symbol
+	outline     []*symbol // the top-level declarations, each with the declarations in it
+	occurrences []occurrence
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	scanner := scan.NewScanner(text)
+	tokens := scanner.ScanTokens()
+	for _, err := range unjoin(scanner.Err()) {
+		var serr *scan.ScanError
+		if errors.As(err, &serr) {
+			d.diagnostics = append(d.diagnostics, d.lineDiagnostic(serr.Line, SeverityError, "", serr.Message))
+		}
+	}
+
+	stmts, err := parse.Parse(tokens)
+	for _, err := range unjoin(err) {
+		var perr *parse.ParseError
+		if errors.As(err, &perr) {
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.tokenRange(perr.Token),
+				Severity: SeverityError,
+				Source:   "go-lox",
+				Message:  perr.Message,
+			})
+		}
+	}
+	d.stmts = stmts
+
+	for _, diag := range lint.Lint(stmts, scanner.Comments) {
+		d.diagnostics = append(d.diagnostics, d.lineDiagnostic(diag.Line, SeverityWarning, diag.Rule, diag.Message))
+	}
+
+	r := &resolver{doc: d, scope: &scope{names: make(map[string]*symbol)}}
+	ast.Walk(r, stmts)
+	// bodies of global functions are resolved after every global is declared, as they run after it is
+	for len(r.scope.deferred) > 0 {
+		f := r.scope.deferred[0]
+		r.scope.deferred = r.scope.deferred[1:]
+		f()
+	}
+	return d
+}
+
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func (d *document) lineDiagnostic(line, severity int, code, message string) Diagnostic {
+	return Diagnostic{Range: d.lineRange(line), Severity: severity, Code: code, Source: "go-lox", Message: message}
+}
+
+func (d *document) lineRange(line int) Range {
+	l := max(line-1, 0)
+	length := 0
+	if l < len(d.lines) {
+		length = utf16Len(strings.TrimRight(d.lines[l], "\r"))
+	}
+	return Range{Start: Position{Line: l}, End: Position{Line: l, Character: length}}
+}
+
+// position converts a 1-based line and rune column to a Position.
+func (d *document) position(line, column int) Position {
+	p := Position{Line: max(line-1, 0)}
+	if p.Line >= len(d.lines) {
+		return p
+	}
+	text := d.lines[p.Line]
+	for c := 1; c < column && text != ""; c++ {
+		r, size := utf8.DecodeRuneInString(text)
+		p.Character += utf16.RuneLen(r)
+		text = text[size:]
+	}
+	return p
+}
+
+func (d *document) tokenRange(t token.Token) Range {
+	start := d.position(t.Line, t.Column)
+	end := start
+	if i := strings.LastIndexByte(t.Lexeme, '\n'); i >= 0 {
+		end.Line += strings.Count(t.Lexeme, "\n")
+		end.Character = utf16Len(t.Lexeme[i+1:])
+	} else {
+		end.Character += utf16Len(t.Lexeme)
+	}
+	return Range{Start: start, End: end}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// occurrenceAt returns the identifier under pos, if it names a known symbol.
+func (d *document) occurrenceAt(pos Position) (occurrence, bool) {
+	for _, o := range d.occurrences {
+		r := d.tokenRange(o.tok)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return o, true
+		}
+	}
+	return occurrence{}, false
+}
+
+// documentSymbols converts symbols of the outline, and the symbols declared in them, to DocumentSymbols.
+func (d *document) documentSymbols(symbols []*symbol) []DocumentSymbol {
+	rslt := []DocumentSymbol{}
+	for _, sym := range symbols {
+		ds := DocumentSymbol{
+			Name:           sym.name.Lexeme,
+			Detail:         sym.detail,
+			Kind:           sym.kind,
+			Range:          Range{Start: d.tokenRange(sym.start).Start, End: d.tokenRange(sym.end).End},
+			SelectionRange: d.tokenRange(sym.name),
+		}
+		if len(sym.children) > 0 {
+			ds.Children = d.documentSymbols(sym.children)
+		}
+		rslt = append(rslt, ds)
+	}
+	return rslt
+}
+
+func (d *document) hover(o occurrence) *Hover {
+	text := fmt.Sprintf("```lox\n%v\n```\ndeclared on line %v", o.sym.detail, o.sym.name.Line)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: d.tokenRange(o.tok)}
+}
+
+type scope struct {
+	parent   *scope
+	names    map[string]*symbol
+	deferred []func() // function bodies to resolve once the global scope is complete
+}
+
+func (s *scope) lookup(name string) *symbol {
+	for ; s != nil; s = s.parent {
+		if sym, ok := s.names[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// resolver is an ast.Visitor that links every variable use to its declaration, following the same block scoping as
+// the interpreter.
+type resolver struct {
+	doc   *document
+	scope *scope
+	fn    *symbol // the function whose body is being resolved, if any
+}
+
+// nested returns a resolver for a scope inside the current one.
+func (r *resolver) nested(fn *symbol) *resolver {
+	return &resolver{doc: r.doc, scope: &scope{parent: r.scope, names: make(map[string]*symbol)}, fn: fn}
+}
+
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.Block:
+		ast.Walk(r.nested(r.fn), n.Statements)
+		return nil
+	case *ast.Var:
+		if n.Expression != nil {
+			ast.Walk(r, n.Expression)
+		}
+		r.outline(r.declare(n.Name, SymbolKindVariable, "var "+n.Name.Lexeme))
+		return nil
+	case *ast.Function:
+		params := make([]string, len(n.Params))
+		for i, param := range n.Params {
+			params[i] = param.Lexeme
+		}
+		sym := r.declare(n.Name, SymbolKindFunction, fmt.Sprintf("fun %v(%v)", n.Name.Lexeme, strings.Join(params, ", ")))
+		sym.start, sym.end = n.Keyword, n.Body.RightBrace
+		r.outline(sym)
+		inner := r.nested(sym)
+		for _, param := range n.Params {
+			inner.declare(param, SymbolKindVariable, fmt.Sprintf("%v (parameter of %v)", param.Lexeme, n.Name.Lexeme))
+		}
+		r.body(inner, n.Body)
+		return nil
+	case *ast.Lambda:
+		// what a lambda declares stays out of the outline, as the lambda itself has no name to list it under
+		inner := r.nested(nil)
+		for _, param := range n.Params {
+			inner.declare(param, SymbolKindVariable, fmt.Sprintf("%v (parameter of lambda)", param.Lexeme))
+		}
+		r.body(inner, n.Body)
+		return nil
+	case *ast.For:
+		ast.Walk(r, n.Iterable)
+		inner := r.nested(r.fn)
+		inner.declare(n.Name, SymbolKindVariable, fmt.Sprintf("%v (loop variable)", n.Name.Lexeme))
+		ast.Walk(inner, n.Body)
+		return nil
+	case *ast.Import:
+		if len(n.Names) == 0 {
+			r.outline(r.declare(n.Alias, SymbolKindModule, fmt.Sprintf("import %v as %v", n.Path.Lexeme, n.Alias.Lexeme)))
+		}
+		for _, name := range n.Names {
+			r.outline(r.declare(name, SymbolKindVariable, fmt.Sprintf("from %v import %v", n.Path.Lexeme, name.Lexeme)))
+		}
+		return nil
+	case *ast.Variable:
+		r.use(n.Name)
+	case *ast.Assign:
+		r.use(n.Name)
+	}
+	return r
+}
+
+// body resolves the body of a function or lambda with inner, or defers it if the function is global.
+func (r *resolver) body(inner *resolver, body *ast.Block) {
+	if r.scope.parent == nil {
+		r.scope.deferred = append(r.scope.deferred, func() { ast.Walk(inner, body) })
+		return
+	}
+	ast.Walk(inner, body)
+}
+
+func (r *resolver) declare(name token.Token, kind int, detail string) *symbol {
+	sym := &symbol{name: name, kind: kind, detail: detail, start: name, end: name}
+	r.scope.names[name.Lexeme] = sym
+	r.doc.symbols = append(r.doc.symbols, sym)
+	r.doc.occurrences = append(r.doc.occurrences, occurrence{tok: name, sym: sym})
+	return sym
+}
+
+// outline lists a variable, function or import in the document outline: under the function it is declared in, or at
+// the top if it is global. Parameters, loop variables and the variables of blocks outside functions are left out.
+func (r *resolver) outline(sym *symbol) {
+	switch {
+	case r.fn != nil:
+		r.fn.children = append(r.fn.children, sym)
+	case r.scope.parent == nil:
+		r.doc.outline = append(r.doc.outline, sym)
+	}
+}
+
+func (r *resolver) use(name token.Token) {
+	if sym := r.scope.lookup(name.Lexeme); sym != nil {
+		sym.refs = append(sym.refs, name)
+		r.doc.occurrences = append(r.doc.occurrences, occurrence{tok: name, sym: sym})
+	}
+}
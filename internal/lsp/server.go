@@ -0,0 +1,273 @@
+// package lsp implements a Language Server Protocol server for Lox over a pair of streams, usually stdin and stdout.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/brentellingson/go-lox/internal/highlight"
+	"github.com/brentellingson/go-lox/internal/rpc"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+type Server struct {
+	conn     *rpc.Conn
+	docs     map[string]*document
+	shutdown bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: rpc.NewConn(r, w), docs: make(map[string]*document)}
+}
+
+// Serve handles messages until the client sends exit or closes the stream.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			if err := s.reply(nil, nil, &ResponseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(req)
+		if req.ID == nil {
+			// notifications get no response, even when they fail
+			continue
+		}
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, err error) error {
+	if err != nil {
+		rerr, ok := err.(*ResponseError)
+		if !ok {
+			rerr = &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.conn.Write(errorResponse{JSONRPC: "2.0", ID: id, Error: rerr})
+	}
+	return s.conn.Write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) handle(req request) (any, error) {
+	if s.shutdown {
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.Write(notification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params:  PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}},
+		})
+	case "textDocument/definition":
+		return s.definition(req.Params)
+	case "textDocument/references":
+		return s.references(req.Params)
+	case "textDocument/hover":
+		return s.hover(req.Params)
+	case "textDocument/documentSymbol":
+		return s.documentSymbol(req.Params)
+	case "textDocument/completion":
+		return s.completion(req.Params)
+	case "textDocument/semanticTokens/full":
+		return s.semanticTokens(req.Params)
+	}
+	return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func (s *Server) initialize() (any, error) {
+	var result InitializeResult
+	result.ServerInfo.Name = "go-lox"
+	result.Capabilities = ServerCapabilities{
+		TextDocumentSync:       1, // full
+		DefinitionProvider:     true,
+		ReferencesProvider:     true,
+		HoverProvider:          true,
+		DocumentSymbolProvider: true,
+	}
+	result.Capabilities.SemanticTokensProvider.Legend.TokenTypes = highlight.SemanticTokenTypes
+	result.Capabilities.SemanticTokensProvider.Legend.TokenModifiers = []string{}
+	result.Capabilities.SemanticTokensProvider.Full = true
+	return result, nil
+}
+
+// update reanalyzes a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	diagnostics := doc.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.conn.Write(notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("document %v is not open", uri)}
+	}
+	return doc, nil
+}
+
+// lookup finds the open document and the symbol occurrence at a position.
+func (s *Server) lookup(raw json.RawMessage, params *TextDocumentPositionParams) (*document, occurrence, bool, error) {
+	if err := json.Unmarshal(raw, params); err != nil {
+		return nil, occurrence{}, false, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, occurrence{}, false, err
+	}
+	o, ok := doc.occurrenceAt(params.Position)
+	return doc, o, ok, nil
+}
+
+func (s *Server) definition(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	doc, o, ok, err := s.lookup(raw, &params)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return Location{URI: doc.uri, Range: doc.tokenRange(o.sym.name)}, nil
+}
+
+func (s *Server) references(raw json.RawMessage) (any, error) {
+	var params ReferenceParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, o, ok, err := s.lookup(raw, &params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	locations := []Location{}
+	if !ok {
+		return locations, nil
+	}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(o.sym.name)})
+	}
+	for _, ref := range o.sym.refs {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(ref)})
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	doc, o, ok, err := s.lookup(raw, &params)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return doc.hover(o), nil
+}
+
+func (s *Server) documentSymbol(raw json.RawMessage) (any, error) {
+	var params DocumentSymbolParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return doc.documentSymbols(doc.outline), nil
+}
+
+func (s *Server) completion(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	for _, k := range scan.Keywords() {
+		items = append(items, CompletionItem{Label: k, Kind: CompletionItemKindKeyword})
+	}
+	seen := make(map[string]bool)
+	for _, sym := range doc.symbols {
+		if seen[sym.name.Lexeme] {
+			continue
+		}
+		seen[sym.name.Lexeme] = true
+		kind := CompletionItemKindVariable
+		switch sym.kind {
+		case SymbolKindModule:
+			kind = CompletionItemKindModule
+		case SymbolKindFunction:
+			kind = CompletionItemKindFunction
+		}
+		items = append(items, CompletionItem{Label: sym.name.Lexeme, Kind: kind, Detail: sym.detail})
+	}
+	return items, nil
+}
+
+func (s *Server) semanticTokens(raw json.RawMessage) (any, error) {
+	var params SemanticTokensParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	data := highlight.SemanticTokens(doc.text)
+	if data == nil {
+		data = []int{}
+	}
+	return SemanticTokens{Data: data}, nil
+}
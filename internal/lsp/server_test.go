@@ -0,0 +1,213 @@
+package lsp_test
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/lsp"
+	"github.com/brentellingson/go-lox/internal/rpc"
+)
+
+// client drives a Server running on the other end of a pair of pipes.
+type client struct {
+	t    *testing.T
+	conn *rpc.Conn
+	id   int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error)
+	go func() {
+		err := lsp.NewServer(inR, outW).Serve()
+		outW.Close()
+		done <- err
+	}()
+	c := &client{t: t, conn: rpc.NewConn(outR, inW)}
+	t.Cleanup(func() {
+		c.notify("exit", nil)
+		if err := <-done; err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	})
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.Write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// call sends a request and decodes the result of its response into result, skipping any notifications before it.
+func (c *client) call(method string, params, result any) {
+	c.t.Helper()
+	c.id++
+	if err := c.conn.Write(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg, err := c.conn.Read()
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		var resp struct {
+			ID     *int               `json:"id"`
+			Result json.RawMessage    `json:"result"`
+			Error  *lsp.ResponseError `json:"error"`
+		}
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			c.t.Fatal(err)
+		}
+		if resp.ID == nil {
+			continue
+		}
+		if *resp.ID != c.id {
+			c.t.Fatalf("%v: response to request %v, want %v", method, *resp.ID, c.id)
+		}
+		if resp.Error != nil {
+			c.t.Fatalf("%v: error %v", method, resp.Error.Message)
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("%v: result %s: %v", method, resp.Result, err)
+		}
+		return
+	}
+}
+
+// didOpen opens a document and returns the diagnostics the server publishes for it.
+func (c *client) didOpen(uri, text string) []lsp.Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{TextDocument: lsp.TextDocumentItem{URI: uri, Text: text}})
+	msg, err := c.conn.Read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var n struct {
+		Method string                       `json:"method"`
+		Params lsp.PublishDiagnosticsParams `json:"params"`
+	}
+	if err := json.Unmarshal(msg, &n); err != nil {
+		c.t.Fatal(err)
+	}
+	if n.Method != "textDocument/publishDiagnostics" || n.Params.URI != uri {
+		c.t.Fatalf("didOpen: got %s, want the diagnostics of %v", msg, uri)
+	}
+	return n.Params.Diagnostics
+}
+
+func position(uri string, line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
+
+const program = `var smile = "😀"; var x = 1;
+fun add(a, b) {
+  var sum = a + b;
+  fun half() { return sum / 2; }
+  return half();
+}
+{ var local = 1; }
+print add(x, 2);
+`
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	var init lsp.InitializeResult
+	c.call("initialize", map[string]any{}, &init)
+	if !init.Capabilities.HoverProvider || !init.Capabilities.DefinitionProvider || !init.Capabilities.DocumentSymbolProvider {
+		t.Errorf("initialize: capabilities = %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	const uri = "file:///program.lox"
+	diagnostics := c.didOpen(uri, program)
+	if len(diagnostics) != 1 || diagnostics[0].Code != "unused-variable" || diagnostics[0].Range.Start.Line != 6 {
+		t.Errorf("didOpen: diagnostics = %+v, want a warning that local is not used", diagnostics)
+	}
+
+	// x follows an emoji, which takes two UTF-16 code units but one rune
+	var hover lsp.Hover
+	c.call("textDocument/hover", position(uri, 0, 22), &hover)
+	if !strings.Contains(hover.Contents.Value, "var x") {
+		t.Errorf("hover: contents = %q, want the declaration of x", hover.Contents.Value)
+	}
+	if want := (lsp.Range{Start: lsp.Position{Line: 0, Character: 22}, End: lsp.Position{Line: 0, Character: 23}}); hover.Range != want {
+		t.Errorf("hover: range = %+v, want %+v", hover.Range, want)
+	}
+
+	var location lsp.Location
+	c.call("textDocument/definition", position(uri, 7, 10), &location)
+	if want := (lsp.Location{URI: uri, Range: lsp.Range{
+		Start: lsp.Position{Line: 0, Character: 22},
+		End:   lsp.Position{Line: 0, Character: 23},
+	}}); location != want {
+		t.Errorf("definition of x: %+v, want %+v", location, want)
+	}
+	c.call("textDocument/definition", position(uri, 3, 22), &location)
+	if location.Range.Start != (lsp.Position{Line: 2, Character: 6}) {
+		t.Errorf("definition of sum: %+v, want line 2 character 6", location)
+	}
+
+	var symbols []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &symbols)
+	if got, want := outline(symbols), []string{"smile", "x", "add(sum, half)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("documentSymbol: %v, want %v", got, want)
+	}
+	if add := symbols[2]; add.Range.Start != (lsp.Position{Line: 1}) || add.Range.End != (lsp.Position{Line: 5, Character: 1}) {
+		t.Errorf("documentSymbol: add spans %+v, want lines 1 to 5", add.Range)
+	}
+}
+
+// outline lists the names of symbols, each followed by the names of its children.
+func outline(symbols []lsp.DocumentSymbol) []string {
+	var names []string
+	for _, sym := range symbols {
+		name := sym.Name
+		if len(sym.Children) > 0 {
+			name += "(" + strings.Join(outline(sym.Children), ", ") + ")"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	var init lsp.InitializeResult
+	c.call("initialize", map[string]any{}, &init)
+
+	diagnostics := c.didOpen("file:///broken.lox", `var s = "😀"; print ;`)
+	if len(diagnostics) != 1 {
+		t.Fatalf("didOpen: diagnostics = %+v, want one", diagnostics)
+	}
+	d := diagnostics[0]
+	want := lsp.Range{Start: lsp.Position{Line: 0, Character: 20}, End: lsp.Position{Line: 0, Character: 21}}
+	if d.Severity != lsp.SeverityError || d.Message != "Expect expression." || d.Range != want {
+		t.Errorf("didOpen: diagnostic = %+v, want an error at %+v", d, want)
+	}
+}
+
+func TestForwardReference(t *testing.T) {
+	c := newClient(t)
+	var init lsp.InitializeResult
+	c.call("initialize", map[string]any{}, &init)
+
+	const uri = "file:///forward.lox"
+	c.didOpen(uri, "fun f() { return g(); }\nfun g() {}\nvar h = () => g();\n")
+	for _, pos := range []lsp.TextDocumentPositionParams{position(uri, 0, 17), position(uri, 2, 14)} {
+		var location *lsp.Location
+		c.call("textDocument/definition", pos, &location)
+		if location == nil || location.Range.Start != (lsp.Position{Line: 1, Character: 4}) {
+			t.Errorf("definition of g at %+v: %+v, want line 1 character 4", pos.Position, location)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// package optimize simplifies Lox syntax trees before they are interpreted.
+package optimize
+
+import (
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+type Level int
+
+const (
+	// O0 leaves the program as parsed.
+	O0 Level = iota
+	// O1 folds constant expressions and removes branches that can never run.
+	O1
+)
+
+// Optimize returns an optimized copy of stmts; the original tree is left unchanged. Constant expressions are folded by
+// evaluating them with the interpreter itself, so a folded program prints exactly what the original would have, and
+// expressions that would fail at runtime are left in place to fail there.
+func Optimize(stmts []ast.Stmt, level Level) []ast.Stmt {
+	if level == O0 {
+		return stmts
+	}
+	return ast.Rewrite(stmts, fold).([]ast.Stmt)
+}
+
+// Fold returns a copy of expr with its constant subexpressions folded.
+func Fold(expr ast.Expr) ast.Expr {
+	return ast.Rewrite(expr, fold).(ast.Expr)
+}
+
+func fold(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.Grouping:
+		if lit, ok := n.Expression.(*ast.Literal); ok {
+			return lit
+		}
+	case *ast.Unary:
+		if n.Operator.Type == token.BANG {
+			if inner, ok := n.Right.(*ast.Unary); ok && inner.Operator.Type == token.BANG && isBool(inner.Right) {
+				return inner.Right
+			}
+		}
+		if _, ok := n.Right.(*ast.Literal); ok {
+			return evaluate(n)
+		}
+	case *ast.Binary:
+		_, left := n.Left.(*ast.Literal)
+		_, right := n.Right.(*ast.Literal)
+		if left && right {
+			return evaluate(n)
+		}
+	case *ast.Logical:
+		if left, ok := n.Left.(*ast.Literal); ok {
+			truthy := engine.IsTruthy(left.Value)
+			if truthy == (n.Operator.Type == token.OR) {
+				return left
+			}
+			return n.Right
+		}
+	case *ast.If:
+		if cond, ok := n.Condition.(*ast.Literal); ok {
+			if engine.IsTruthy(cond.Value) {
+				return n.ThenBranch
+			}
+			if n.ElseBranch != nil {
+				return n.ElseBranch
+			}
+			return &ast.Block{}
+		}
+	case *ast.While:
+		if cond, ok := n.Condition.(*ast.Literal); ok && !engine.IsTruthy(cond.Value) {
+			return &ast.Block{}
+		}
+	}
+	return node
+}
+
+// evaluate replaces an expression whose operands are all literals with its value, unless evaluating it fails.
+func evaluate(expr ast.Expr) ast.Expr {
+	v, err := engine.NewInterpreter().Evaluate(expr)
+	if err != nil {
+		return expr
+	}
+	return &ast.Literal{Value: v}
+}
+
+// isBool reports whether expr always evaluates to a boolean, so that !!expr can be replaced by expr.
+func isBool(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Literal:
+		_, ok := e.Value.(bool)
+		return ok
+	case *ast.Grouping:
+		return isBool(e.Expression)
+	case *ast.Unary:
+		return e.Operator.Type == token.BANG
+	case *ast.Binary:
+		switch e.Operator.Type {
+		case token.EQUAL_EQUAL, token.BANG_EQUAL, token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+			return true
+		}
+	case *ast.Logical:
+		return isBool(e.Left) && isBool(e.Right)
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package optimize_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/optimize"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func parseSource(source string) ([]ast.Stmt, error) {
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		return nil, err
+	}
+	return parse.Parse(tokens)
+}
+
+// run interprets stmts, returning what they print followed by the error they stop with, if any.
+func run(stmts []ast.Stmt) string {
+	var out bytes.Buffer
+	i := engine.NewInterpreter(engine.WithStdout(&out), engine.WithTimeout(5*time.Second))
+	if _, err := i.Interpret(stmts); err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	return out.String()
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"arithmetic", "print 1 + 2 * 3;", "(print 7)"},
+		{"grouping", "print (1 + 2) * 3;", "(print 9)"},
+		{"strings", `print "a" + "b";`, `(print "ab")`},
+		{"comparison", "print 1 < 2 == true;", "(print true)"},
+		{"unary", "print -(2 - 5);", "(print 3)"},
+		{"partly constant", "var a = 1; print a + 2 * 3;", "(var a = 1)\n(print (+ a 6))"},
+		{"runtime error kept", `print 1 + "a";`, `(print (+ 1 "a"))`},
+		{"and", "print false and x;", "(print false)"},
+		{"or", "print 1 or x;", "(print 1)"},
+		{"or right", "print nil or x;", "(print x)"},
+		{"if true", "if (1 < 2) print 1; else print 2;", "(print 1)"},
+		{"if false", "if (nil) print 1; else print 2;", "(print 2)"},
+		{"if false without else", "if (false) print 1;", "(block)"},
+		{"while false", "while (false) print 1;", "(block)"},
+		{"while true kept", "fun f() { while (true) return 1; } print f();", "(fun f () (while true (return 1)))\n(print (call f))"},
+		{"not not", "var a = 1; print !!(a < 2);", "(var a = 1)\n(print (group (< a 2)))"},
+		{"not not kept", "var a = 1; print !!a;", "(var a = 1)\n(print (! (! a)))"},
+		{"generator yield folded", `fun g() { if (false) yield 1; print "body ran"; } print g();`, "(fun g () (block) (print \"body ran\"))\n(print (call g))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, err := parseSource(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := internal.PrintStmts(stmts)
+			optimized := optimize.Optimize(stmts, optimize.O1)
+			if got := internal.PrintStmts(optimized); got != tt.want+"\n" {
+				t.Errorf("optimized to\n%v\nwant\n%v", got, tt.want)
+			}
+			if after := internal.PrintStmts(stmts); after != before {
+				t.Errorf("Optimize changed the original tree from %v to %v", before, after)
+			}
+			if got, want := run(optimized), run(stmts); got != want {
+				t.Errorf("optimized program printed %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// TestOptimizeConformance checks that the optimizer does not change what any conformance script prints.
+func TestOptimizeConformance(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/conformance/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no conformance scripts found")
+	}
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts, err := parseSource(string(source))
+		if err != nil {
+			continue // scripts testing compile errors never run
+		}
+		if got, want := run(optimize.Optimize(stmts, optimize.O1)), run(stmts); got != want {
+			t.Errorf("%v: optimized script printed %q, want %q", path, got, want)
+		}
+	}
+}
@@ -39,9 +39,18 @@ func (t *TokenBuffer) Peek() token.Token {
 	return t.tokens[t.current+1]
 }
 
+// PeekAt returns the token n positions after the current token without advancing the current token. Past the end of
+// the stream, PeekAt returns the EOF token.
+func (t *TokenBuffer) PeekAt(n int) token.Token {
+	if t.current+n >= len(t.tokens) {
+		return t.tokens[len(t.tokens)-1]
+	}
+	return t.tokens[t.current+n]
+}
+
 // IsAtEnd returns true if the current token is the last token in the stream.
 func (t *TokenBuffer) IsAtEnd() bool {
-	return t.tokens[t.current].Type == token.EOF || t.current >= len(t.tokens)-1
+	return t.current >= len(t.tokens)-1 || t.tokens[t.current].Type == token.EOF
 }
 
 // Check returns true if the current token matches any of the specified types, without advancing the current token.
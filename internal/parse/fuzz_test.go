@@ -0,0 +1,46 @@
+package parse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// addSeeds adds the conformance scripts and the example scripts to the seed corpus of f.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{"../../testdata/conformance/*/*.lox", "../../scripts/*.lox", "../../script.lox"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(source))
+		}
+	}
+}
+
+// FuzzParse checks that parsing never panics, and that it either fails or returns no nil statements.
+func FuzzParse(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens, err := scan.Scan(source)
+		if err != nil {
+			return
+		}
+		stmts, err := Parse(tokens)
+		if err != nil {
+			return
+		}
+		for i, stmt := range stmts {
+			if stmt == nil {
+				t.Fatalf("statement %v is nil", i)
+			}
+		}
+	})
+}
@@ -21,8 +21,15 @@ func Parse(tokens []token.Token) ([]ast.Stmt, error) {
 	return parser.Parse()
 }
 
+// maxNesting bounds how deeply statements and expressions may nest, so that the parser, and everything that walks the
+// tree it returns, fails cleanly rather than overflowing the stack.
+const maxNesting = 1000
+
 type Parser struct {
-	buff *TokenBuffer
+	buff      *TokenBuffer
+	functions int  // depth of function bodies being parsed
+	yields    bool // whether the innermost function body being parsed yields
+	nesting   int  // depth of statements and expressions being parsed
 }
 
 func NewParser(tokens []token.Token) *Parser {
@@ -44,6 +51,20 @@ func (p *Parser) Parse() ([]ast.Stmt, error) {
 	return stmts, errors.Join(errs...)
 }
 
+// nest enters a nested statement or expression, failing if there are too many around it. The caller must call p.unnest
+// when it is done, whether or not nest fails.
+func (p *Parser) nest() error {
+	p.nesting++
+	if p.nesting > maxNesting {
+		return &ParseError{p.buff.Current(), "Too much nesting."}
+	}
+	return nil
+}
+
+func (p *Parser) unnest() {
+	p.nesting--
+}
+
 func (p *Parser) synchronize() {
 	for !p.buff.IsAtEnd() {
 		prev := p.buff.Advance()
@@ -53,12 +74,15 @@ func (p *Parser) synchronize() {
 		if p.buff.Check(
 			token.CLASS,
 			token.FOR,
+			token.FROM,
 			token.FUN,
 			token.IF,
+			token.IMPORT,
 			token.PRINT,
 			token.RETURN,
 			token.VAR,
 			token.WHILE,
+			token.YIELD,
 		) {
 			return
 		}
@@ -69,6 +93,16 @@ func (p *Parser) declaration() (ast.Stmt, error) {
 	if p.buff.Match(token.VAR) {
 		return p.varStatement()
 	}
+	// fun followed by '(' starts a lambda, in an expression statement
+	if p.buff.Check(token.FUN) && p.buff.Peek().Type != token.LEFT_PAREN {
+		return p.funStatement()
+	}
+	if p.buff.Check(token.IMPORT) {
+		return p.importStatement()
+	}
+	if p.buff.Check(token.FROM) {
+		return p.fromStatement()
+	}
 
 	return p.statement()
 }
@@ -95,17 +129,135 @@ func (p *Parser) varStatement() (ast.Stmt, error) {
 	return &ast.Var{Name: name, Expression: initializer}, nil
 }
 
+func (p *Parser) funStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if !p.buff.Check(token.IDENTIFIER) {
+		return nil, &ParseError{p.buff.Current(), "Expect function name."}
+	}
+	name := p.buff.Advance()
+	if !p.buff.Match(token.LEFT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect '(' after function name."}
+	}
+	params, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	body, yields, err := p.functionBody()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Function{Keyword: keyword, Name: name, Params: params, Body: body, Generator: yields}, nil
+}
+
+// parameters parses the parameters of a function after its '(', through the closing ')'.
+func (p *Parser) parameters() ([]token.Token, error) {
+	var params []token.Token
+	if !p.buff.Check(token.RIGHT_PAREN) {
+		for {
+			if len(params) >= 255 {
+				return nil, &ParseError{p.buff.Current(), "Can't have more than 255 parameters."}
+			}
+			if !p.buff.Check(token.IDENTIFIER) {
+				return nil, &ParseError{p.buff.Current(), "Expect parameter name."}
+			}
+			params = append(params, p.buff.Advance())
+			if !p.buff.Match(token.COMMA) {
+				break
+			}
+		}
+	}
+	if !p.buff.Match(token.RIGHT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect ')' after parameters."}
+	}
+	return params, nil
+}
+
+// functionBody parses the body of a function, and reports whether it yields, outside of the functions declared in it.
+func (p *Parser) functionBody() (*ast.Block, bool, error) {
+	if !p.buff.Check(token.LEFT_BRACE) {
+		return nil, false, &ParseError{p.buff.Current(), "Expect '{' before function body."}
+	}
+	outer := p.yields
+	p.functions++
+	p.yields = false
+	body, err := p.blockStatement()
+	yields := p.yields
+	p.functions--
+	p.yields = outer
+	if err != nil {
+		return nil, false, err
+	}
+	return body.(*ast.Block), yields, nil
+}
+
+func (p *Parser) importStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if !p.buff.Check(token.STRING) {
+		return nil, &ParseError{p.buff.Current(), "Expect module path after 'import'."}
+	}
+	path := p.buff.Advance()
+	if !p.buff.Match(token.AS) {
+		return nil, &ParseError{p.buff.Current(), "Expect 'as' after module path."}
+	}
+	if !p.buff.Check(token.IDENTIFIER) {
+		return nil, &ParseError{p.buff.Current(), "Expect module name after 'as'."}
+	}
+	alias := p.buff.Advance()
+	if !p.buff.Match(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		return nil, &ParseError{p.buff.Current(), "Expect ';' after import."}
+	}
+	return &ast.Import{Keyword: keyword, Path: path, Alias: alias}, nil
+}
+
+func (p *Parser) fromStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if !p.buff.Check(token.STRING) {
+		return nil, &ParseError{p.buff.Current(), "Expect module path after 'from'."}
+	}
+	path := p.buff.Advance()
+	if !p.buff.Match(token.IMPORT) {
+		return nil, &ParseError{p.buff.Current(), "Expect 'import' after module path."}
+	}
+	var names []token.Token
+	for {
+		if !p.buff.Check(token.IDENTIFIER) {
+			return nil, &ParseError{p.buff.Current(), "Expect name to import."}
+		}
+		names = append(names, p.buff.Advance())
+		if !p.buff.Match(token.COMMA) {
+			break
+		}
+	}
+	if !p.buff.Match(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		return nil, &ParseError{p.buff.Current(), "Expect ';' after import."}
+	}
+	return &ast.Import{Keyword: keyword, Path: path, Names: names}, nil
+}
+
 func (p *Parser) statement() (ast.Stmt, error) {
-	if p.buff.Match(token.WHILE) {
+	defer p.unnest()
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	if p.buff.Check(token.WHILE) {
 		return p.whileStatement()
 	}
-	if p.buff.Match(token.IF) {
+	if p.buff.Check(token.FOR) {
+		return p.forStatement()
+	}
+	if p.buff.Check(token.IF) {
 		return p.ifStatement()
 	}
-	if p.buff.Match(token.PRINT) {
+	if p.buff.Check(token.PRINT) {
 		return p.printStatement()
 	}
-	if p.buff.Match(token.LEFT_BRACE) {
+	if p.buff.Check(token.RETURN) {
+		return p.returnStatement()
+	}
+	if p.buff.Check(token.YIELD) {
+		return p.yieldStatement()
+	}
+	if p.buff.Check(token.LEFT_BRACE) {
 		return p.blockStatement()
 	}
 
@@ -113,6 +265,7 @@ func (p *Parser) statement() (ast.Stmt, error) {
 }
 
 func (p *Parser) whileStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
 	if !p.buff.Match(token.LEFT_PAREN) {
 		return nil, &ParseError{p.buff.Current(), "Expect '(' after 'while'."}
 	}
@@ -127,10 +280,37 @@ func (p *Parser) whileStatement() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ast.While{Condition: condition, Body: body}, nil
+	return &ast.While{Keyword: keyword, Condition: condition, Body: body}, nil
+}
+
+func (p *Parser) forStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if !p.buff.Match(token.LEFT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect '(' after 'for'."}
+	}
+	if !p.buff.Check(token.IDENTIFIER) {
+		return nil, &ParseError{p.buff.Current(), "Expect loop variable name."}
+	}
+	name := p.buff.Advance()
+	if !p.buff.Match(token.IN) {
+		return nil, &ParseError{p.buff.Current(), "Expect 'in' after loop variable."}
+	}
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.buff.Match(token.RIGHT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect ')' after for clauses."}
+	}
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.For{Keyword: keyword, Name: name, Iterable: iterable, Body: body}, nil
 }
 
 func (p *Parser) ifStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
 	if !p.buff.Match(token.LEFT_PAREN) {
 		return nil, &ParseError{p.buff.Current(), "Expect '(' after 'if'."}
 	}
@@ -153,10 +333,11 @@ func (p *Parser) ifStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	return &ast.If{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
+	return &ast.If{Keyword: keyword, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
 }
 
 func (p *Parser) printStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
 	expr, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -164,10 +345,50 @@ func (p *Parser) printStatement() (ast.Stmt, error) {
 	if !p.buff.Match(token.SEMICOLON) && !p.buff.IsAtEnd() {
 		return nil, &ParseError{p.buff.Current(), "Expect ';' after value."}
 	}
-	return &ast.Print{Expression: expr}, nil
+	return &ast.Print{Keyword: keyword, Expression: expr}, nil
+}
+
+func (p *Parser) returnStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if p.functions == 0 {
+		return nil, &ParseError{keyword, "Can't return from top-level code."}
+	}
+	var value ast.Expr
+	if !p.buff.Check(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		var err error
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !p.buff.Match(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		return nil, &ParseError{p.buff.Current(), "Expect ';' after return value."}
+	}
+	return &ast.Return{Keyword: keyword, Value: value}, nil
+}
+
+func (p *Parser) yieldStatement() (ast.Stmt, error) {
+	keyword := p.buff.Advance()
+	if p.functions == 0 {
+		return nil, &ParseError{keyword, "Can't yield from top-level code."}
+	}
+	p.yields = true
+	var value ast.Expr
+	if !p.buff.Check(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		var err error
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !p.buff.Match(token.SEMICOLON) && !p.buff.IsAtEnd() {
+		return nil, &ParseError{p.buff.Current(), "Expect ';' after yield value."}
+	}
+	return &ast.Yield{Keyword: keyword, Value: value}, nil
 }
 
 func (p *Parser) blockStatement() (ast.Stmt, error) {
+	leftBrace := p.buff.Advance()
 	var stmts []ast.Stmt
 	for !p.buff.IsAtEnd() && !p.buff.Check(token.RIGHT_BRACE) {
 		stmt, err := p.declaration()
@@ -176,10 +397,11 @@ func (p *Parser) blockStatement() (ast.Stmt, error) {
 		}
 		stmts = append(stmts, stmt)
 	}
-	if !p.buff.Match(token.RIGHT_BRACE) {
+	if !p.buff.Check(token.RIGHT_BRACE) {
 		return nil, &ParseError{p.buff.Current(), "Expect '}' after block."}
 	}
-	return &ast.Block{Statements: stmts}, nil
+	rightBrace := p.buff.Advance()
+	return &ast.Block{LeftBrace: leftBrace, Statements: stmts, RightBrace: rightBrace}, nil
 }
 
 func (p *Parser) expressionStatement() (ast.Stmt, error) {
@@ -198,6 +420,10 @@ func (p *Parser) expression() (ast.Expr, error) {
 }
 
 func (p *Parser) assignment() (ast.Expr, error) {
+	defer p.unnest()
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
 	expr, err := p.or()
 	if err != nil {
 		return nil, err
@@ -223,6 +449,10 @@ func (p *Parser) or() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.OR) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.and()
 		if err != nil {
@@ -240,6 +470,10 @@ func (p *Parser) and() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.AND) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.equality()
 		if err != nil {
@@ -257,6 +491,10 @@ func (p *Parser) equality() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.BANG_EQUAL, token.EQUAL_EQUAL) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.comparison()
 		if err != nil {
@@ -274,6 +512,10 @@ func (p *Parser) comparison() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.term()
 		if err != nil {
@@ -291,6 +533,10 @@ func (p *Parser) term() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.PLUS, token.MINUS) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.factor()
 		if err != nil {
@@ -308,6 +554,10 @@ func (p *Parser) factor() (ast.Expr, error) {
 	}
 
 	for p.buff.Check(token.STAR, token.SLASH) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.unary()
 		if err != nil {
@@ -320,6 +570,10 @@ func (p *Parser) factor() (ast.Expr, error) {
 
 func (p *Parser) unary() (ast.Expr, error) {
 	if p.buff.Check(token.BANG, token.MINUS) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		operator := p.buff.Advance()
 		right, err := p.unary()
 		if err != nil {
@@ -327,7 +581,59 @@ func (p *Parser) unary() (ast.Expr, error) {
 		}
 		return &ast.Unary{Operator: operator, Right: right}, nil
 	}
-	return p.primary()
+	return p.call()
+}
+
+func (p *Parser) call() (ast.Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.buff.Check(token.LEFT_PAREN, token.DOT) {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		if p.buff.Check(token.LEFT_PAREN) {
+			expr, err = p.finishCall(expr)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p.buff.Advance()
+		if !p.buff.Check(token.IDENTIFIER) {
+			return nil, &ParseError{p.buff.Current(), "Expect property name after '.'."}
+		}
+		expr = &ast.Get{Object: expr, Name: p.buff.Advance()}
+	}
+	return expr, nil
+}
+
+func (p *Parser) finishCall(callee ast.Expr) (ast.Expr, error) {
+	p.buff.Advance()
+	var args []ast.Expr
+	if !p.buff.Check(token.RIGHT_PAREN) {
+		for {
+			if len(args) >= 255 {
+				return nil, &ParseError{p.buff.Current(), "Can't have more than 255 arguments."}
+			}
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.buff.Match(token.COMMA) {
+				break
+			}
+		}
+	}
+	if !p.buff.Check(token.RIGHT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect ')' after arguments."}
+	}
+	paren := p.buff.Advance()
+	return &ast.Call{Callee: callee, Paren: paren, Arguments: args}, nil
 }
 
 func (p *Parser) primary() (ast.Expr, error) {
@@ -351,6 +657,14 @@ func (p *Parser) primary() (ast.Expr, error) {
 		return &ast.Variable{Name: p.buff.Advance()}, nil
 	}
 
+	if p.buff.Check(token.FUN) {
+		return p.lambda()
+	}
+
+	if p.buff.Check(token.LEFT_PAREN) && p.arrowAhead() {
+		return p.arrow()
+	}
+
 	if p.buff.Match(token.LEFT_PAREN) {
 		expr, err := p.expression()
 		if err != nil {
@@ -365,3 +679,64 @@ func (p *Parser) primary() (ast.Expr, error) {
 
 	return nil, &ParseError{p.buff.Current(), "Expect expression."}
 }
+
+func (p *Parser) lambda() (ast.Expr, error) {
+	keyword := p.buff.Advance()
+	if !p.buff.Match(token.LEFT_PAREN) {
+		return nil, &ParseError{p.buff.Current(), "Expect '(' after 'fun'."}
+	}
+	params, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	body, yields, err := p.functionBody()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Lambda{Keyword: keyword, Params: params, Body: body, Generator: yields}, nil
+}
+
+// arrowAhead reports whether the current '(' starts the parameters of an arrow function rather than a grouping.
+func (p *Parser) arrowAhead() bool {
+	n := 1
+	if p.buff.PeekAt(n).Type != token.RIGHT_PAREN {
+		for {
+			if p.buff.PeekAt(n).Type != token.IDENTIFIER {
+				return false
+			}
+			n++
+			if p.buff.PeekAt(n).Type != token.COMMA {
+				break
+			}
+			n++
+		}
+		if p.buff.PeekAt(n).Type != token.RIGHT_PAREN {
+			return false
+		}
+	}
+	return p.buff.PeekAt(n+1).Type == token.ARROW
+}
+
+func (p *Parser) arrow() (ast.Expr, error) {
+	p.buff.Advance()
+	params, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	arrow := p.buff.Advance()
+	if p.buff.Check(token.LEFT_BRACE) {
+		body, yields, err := p.functionBody()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Lambda{Keyword: arrow, Params: params, Body: body, Generator: yields}, nil
+	}
+
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	ret := &ast.Return{Keyword: arrow, Value: value}
+	body := &ast.Block{LeftBrace: arrow, Statements: []ast.Stmt{ret}, RightBrace: arrow}
+	return &ast.Lambda{Keyword: arrow, Params: params, Body: body, ExprBody: true}, nil
+}
@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func TestNesting(t *testing.T) {
+	const deep = 10 * maxNesting
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"groups", strings.Repeat("(", deep)},
+		{"blocks", strings.Repeat("{", deep)},
+		{"unary", strings.Repeat("-", deep) + "1;"},
+		{"assignments", strings.Repeat("a = ", deep) + "1;"},
+		{"binary", "1" + strings.Repeat(" + 1", deep) + ";"},
+		{"calls", "f" + strings.Repeat("()", deep) + ";"},
+		{"properties", "a" + strings.Repeat(".b", deep) + ";"},
+		{"if", strings.Repeat("if (true) ", deep) + "print 1;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scan.Scan(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Parse(tokens)
+			if err == nil || !strings.Contains(err.Error(), "Too much nesting.") {
+				t.Errorf("Parse() error = %v, want too much nesting", err)
+			}
+		})
+	}
+
+	tokens, err := scan.Scan("print " + strings.Repeat("(", maxNesting-10) + "1" + strings.Repeat(")", maxNesting-10) + ";")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(tokens); err != nil {
+		t.Errorf("Parse() error = %v for nesting within the limit", err)
+	}
+}
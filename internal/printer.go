@@ -15,8 +15,104 @@ func PrintAst(expr ast.Expr) string {
 	return result.(string)
 }
 
+// PrintStmts prints each statement as an S-expression on its own line.
+func PrintStmts(stmts []ast.Stmt) string {
+	var b strings.Builder
+	for _, stmt := range stmts {
+		result, err := stmt.Accept(&AstPrinter{})
+		if err != nil {
+			return fmt.Sprintf("error: %v", err)
+		}
+		b.WriteString(result.(string))
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
+
 type AstPrinter struct{}
 
+func (p *AstPrinter) VisitExpressionStmt(stmt *ast.Expression) (any, error) {
+	return p.parenthesize(";", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitPrintStmt(stmt *ast.Print) (any, error) {
+	return p.parenthesize("print", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitVarStmt(stmt *ast.Var) (any, error) {
+	if stmt.Expression == nil {
+		return "(var " + stmt.Name.Lexeme + ")", nil
+	}
+	return p.parenthesize("var "+stmt.Name.Lexeme+" =", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitBlockStmt(stmt *ast.Block) (any, error) {
+	return p.parenthesizeStmts("block", stmt.Statements...)
+}
+
+func (p *AstPrinter) VisitIfStmt(stmt *ast.If) (any, error) {
+	cond, err := stmt.Condition.Accept(p)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.ElseBranch == nil {
+		return p.parenthesizeStmts("if "+cond.(string), stmt.ThenBranch)
+	}
+	return p.parenthesizeStmts("if-else "+cond.(string), stmt.ThenBranch, stmt.ElseBranch)
+}
+
+func (p *AstPrinter) VisitWhileStmt(stmt *ast.While) (any, error) {
+	cond, err := stmt.Condition.Accept(p)
+	if err != nil {
+		return nil, err
+	}
+	return p.parenthesizeStmts("while "+cond.(string), stmt.Body)
+}
+
+func (p *AstPrinter) VisitImportStmt(stmt *ast.Import) (any, error) {
+	var b strings.Builder
+	b.WriteString("(import ")
+	b.WriteString(stmt.Path.Lexeme)
+	if len(stmt.Names) == 0 {
+		b.WriteString(" as " + stmt.Alias.Lexeme)
+	}
+	for _, name := range stmt.Names {
+		b.WriteString(" " + name.Lexeme)
+	}
+	b.WriteRune(')')
+	return b.String(), nil
+}
+
+func (p *AstPrinter) VisitFunctionStmt(stmt *ast.Function) (any, error) {
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+	}
+	return p.parenthesizeStmts("fun "+stmt.Name.Lexeme+" ("+strings.Join(params, " ")+")", stmt.Body.Statements...)
+}
+
+func (p *AstPrinter) VisitReturnStmt(stmt *ast.Return) (any, error) {
+	if stmt.Value == nil {
+		return "(return)", nil
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+func (p *AstPrinter) VisitForStmt(stmt *ast.For) (any, error) {
+	iterable, err := stmt.Iterable.Accept(p)
+	if err != nil {
+		return nil, err
+	}
+	return p.parenthesizeStmts("for "+stmt.Name.Lexeme+" in "+iterable.(string), stmt.Body)
+}
+
+func (p *AstPrinter) VisitYieldStmt(stmt *ast.Yield) (any, error) {
+	if stmt.Value == nil {
+		return "(yield)", nil
+	}
+	return p.parenthesize("yield", stmt.Value)
+}
+
 func (p *AstPrinter) VisitBinaryExpr(expr *ast.Binary) (any, error) {
 	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
@@ -45,6 +141,22 @@ func (p *AstPrinter) VisitLogicalExpr(expr *ast.Logical) (any, error) {
 	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
 
+func (p *AstPrinter) VisitGetExpr(expr *ast.Get) (any, error) {
+	return p.parenthesize(". "+expr.Name.Lexeme, expr.Object)
+}
+
+func (p *AstPrinter) VisitCallExpr(expr *ast.Call) (any, error) {
+	return p.parenthesize("call", append([]ast.Expr{expr.Callee}, expr.Arguments...)...)
+}
+
+func (p *AstPrinter) VisitLambdaExpr(expr *ast.Lambda) (any, error) {
+	params := make([]string, len(expr.Params))
+	for i, param := range expr.Params {
+		params[i] = param.Lexeme
+	}
+	return p.parenthesizeStmts("lambda ("+strings.Join(params, " ")+")", expr.Body.Statements...)
+}
+
 func (p *AstPrinter) parenthesize(name string, exprs ...ast.Expr) (any, error) {
 	var b strings.Builder
 	b.WriteRune('(')
@@ -60,3 +172,19 @@ func (p *AstPrinter) parenthesize(name string, exprs ...ast.Expr) (any, error) {
 	b.WriteRune(')')
 	return b.String(), nil
 }
+
+func (p *AstPrinter) parenthesizeStmts(name string, stmts ...ast.Stmt) (any, error) {
+	var b strings.Builder
+	b.WriteRune('(')
+	b.WriteString(name)
+	for _, s := range stmts {
+		b.WriteRune(' ')
+		v, err := s.Accept(p)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(v.(string))
+	}
+	b.WriteRune(')')
+	return b.String(), nil
+}
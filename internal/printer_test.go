@@ -0,0 +1,48 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+// The golden files in testdata hold each printed form of testdata/program.lox, which has every kind of node.
+
+func parseProgram(t *testing.T) []ast.Stmt {
+	t.Helper()
+	source, err := os.ReadFile(filepath.Join("testdata", "program.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := scan.Scan(string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+// checkGolden compares got with the golden file testdata/program.ext.
+func checkGolden(t *testing.T, ext, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "program."+ext)
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %v; got\n%v", path, got)
+	}
+}
+
+func TestPrintStmts(t *testing.T) {
+	checkGolden(t, "sexpr", internal.PrintStmts(parseProgram(t)))
+}
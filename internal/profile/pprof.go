@@ -0,0 +1,139 @@
+package profile
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format read by go tool pprof, with two sample values:
+// the number of executions and the self time in nanoseconds. Each statement is a function named by frameName, located
+// at its line in the script.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	table := []string{""}
+	index := make(map[string]int64)
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+
+	var b protobuf
+	valueType := func(tag int, typ, unit string) {
+		b.message(tag, func(m *protobuf) {
+			m.int64(1, str(typ))
+			m.int64(2, str(unit))
+		})
+	}
+	valueType(1, "count", "count")
+	valueType(1, "time", "nanoseconds")
+
+	// statements become both a function and a location, with the same ID
+	ids := make(map[ast.Stmt]uint64)
+	var stmts []ast.Stmt
+	for _, key := range p.order {
+		sm := p.samples[key]
+		locations := make([]uint64, len(sm.frames))
+		for i, stmt := range sm.frames {
+			id, ok := ids[stmt]
+			if !ok {
+				id = uint64(len(stmts) + 1)
+				ids[stmt] = id
+				stmts = append(stmts, stmt)
+			}
+			// pprof lists a sample's locations leaf first
+			locations[len(sm.frames)-1-i] = id
+		}
+		b.message(2, func(m *protobuf) {
+			m.packed(1, locations)
+			m.packed(2, []uint64{uint64(sm.count), uint64(sm.self.Nanoseconds())})
+		})
+	}
+
+	for i, stmt := range stmts {
+		id, line := uint64(i+1), int64(ast.StmtLine(stmt))
+		b.message(4, func(m *protobuf) {
+			m.uint64(1, id)
+			m.message(4, func(l *protobuf) {
+				l.uint64(1, id)
+				l.int64(2, line)
+			})
+		})
+	}
+	for i, stmt := range stmts {
+		id, line := uint64(i+1), int64(ast.StmtLine(stmt))
+		name, file := str(p.frameName(stmt)), str(p.path)
+		b.message(5, func(m *protobuf) {
+			m.uint64(1, id)
+			m.int64(2, name)
+			m.int64(3, name)
+			m.int64(4, file)
+			m.int64(5, line)
+		})
+	}
+
+	b.int64(9, p.start.UnixNano())
+	b.int64(10, p.duration.Nanoseconds())
+	valueType(11, "time", "nanoseconds")
+	b.int64(12, 1)
+	// the string table is written last, once every string has been added, and must include the empty string
+	for _, s := range table {
+		b.bytes(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// protobuf encodes the protocol buffer wire format, enough for the pprof profile message.
+type protobuf struct {
+	buf []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	b.buf = binary.AppendUvarint(b.buf, x)
+}
+
+func (b *protobuf) key(tag, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(tag, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bytes(tag int, data []byte) {
+	b.key(tag, 2)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+func (b *protobuf) packed(tag int, xs []uint64) {
+	var m protobuf
+	for _, x := range xs {
+		m.varint(x)
+	}
+	b.bytes(tag, m.buf)
+}
+
+func (b *protobuf) message(tag int, f func(m *protobuf)) {
+	var m protobuf
+	f(&m)
+	b.bytes(tag, m.buf)
+}
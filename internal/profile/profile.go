@@ -0,0 +1,204 @@
+// package profile measures where a Lox program spends its time, statement by statement, through the interpreter's
+// execution hook.
+//
+// Every executing statement is a frame of the profile's call stack, so a statement nested in a loop body has the loop
+// and the body's block as its callers. A function's body is the frame of each call to it, named after the function, so
+// the profile also gives the time spent in each function. Statements run by imported modules are not observed; their
+// time is counted against the import statement.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+)
+
+// Stat is the execution profile of one statement.
+type Stat struct {
+	Stmt  ast.Stmt
+	Line  int
+	Name  string
+	Count int           // times the statement was executed
+	Self  time.Duration // time spent in the statement itself, excluding the statements nested inside it
+	Total time.Duration // time from the statement starting to it finishing, including nested statements
+}
+
+// sample is a distinct call stack, with the statistics of the statement at its top.
+type sample struct {
+	frames []ast.Stmt
+	count  int
+	self   time.Duration
+}
+
+type frame struct {
+	stmt     ast.Stmt
+	start    time.Time
+	children time.Duration
+}
+
+// Profiler is an engine.AfterHook that records how often each statement runs and how long it takes.
+type Profiler struct {
+	path     string
+	start    time.Time
+	duration time.Duration
+	stack    []frame
+	stats    map[ast.Stmt]*Stat
+	samples  map[string]*sample
+	order    []string            // keys of samples in the order first seen
+	bodies   map[ast.Stmt]string // names of function bodies
+}
+
+// New returns a profiler for the script at path.
+func New(path string) *Profiler {
+	return &Profiler{
+		path:    path,
+		stats:   make(map[ast.Stmt]*Stat),
+		samples: make(map[string]*sample),
+		bodies:  make(map[ast.Stmt]string),
+	}
+}
+
+// Run interprets stmts under the profiler.
+func (p *Profiler) Run(stmts []ast.Stmt, opts ...engine.Option) (any, error) {
+	ast.Inspect(stmts, func(n ast.Node) bool {
+		switch f := n.(type) {
+		case *ast.Function:
+			p.bodies[f.Body] = f.Name.Lexeme + "()"
+		case *ast.Lambda:
+			p.bodies[f.Body] = fmt.Sprintf("lambda@%v()", f.Keyword.Line)
+		}
+		return true
+	})
+	p.start = time.Now()
+	defer func() {
+		p.duration = time.Since(p.start)
+	}()
+	return engine.NewInterpreter(append(opts, engine.WithHook(p))...).Interpret(stmts)
+}
+
+// BeforeStmt implements engine.Hook.
+func (p *Profiler) BeforeStmt(stmt ast.Stmt, depth int) error {
+	p.stack = append(p.stack[:depth], frame{stmt: stmt, start: time.Now()})
+	return nil
+}
+
+// Resume implements engine.ResumeHook. The time a generator spends suspended is not counted.
+func (p *Profiler) Resume(stmts []ast.Stmt, depth int) {
+	p.stack = p.stack[:depth]
+	for _, stmt := range stmts {
+		p.stack = append(p.stack, frame{stmt: stmt, start: time.Now()})
+	}
+}
+
+// AfterStmt implements engine.AfterHook.
+func (p *Profiler) AfterStmt(stmt ast.Stmt, depth int) {
+	f := p.stack[depth]
+	elapsed := time.Since(f.start)
+	self := elapsed - f.children
+	if depth > 0 {
+		p.stack[depth-1].children += elapsed
+	}
+
+	s, ok := p.stats[stmt]
+	if !ok {
+		s = &Stat{Stmt: stmt, Line: ast.StmtLine(stmt), Name: p.describe(stmt)}
+		p.stats[stmt] = s
+	}
+	s.Count++
+	s.Self += self
+	// a statement already on the stack is running inside itself; its outer execution counts the time
+	recursive := false
+	for _, outer := range p.stack[:depth] {
+		recursive = recursive || outer.stmt == stmt
+	}
+	if !recursive {
+		s.Total += elapsed
+	}
+
+	names := make([]string, depth+1)
+	for i, f := range p.stack[:depth+1] {
+		names[i] = p.frameName(f.stmt)
+	}
+	key := strings.Join(names, ";")
+	sm, ok := p.samples[key]
+	if !ok {
+		frames := make([]ast.Stmt, depth+1)
+		for i, f := range p.stack[:depth+1] {
+			frames[i] = f.stmt
+		}
+		sm = &sample{frames: frames}
+		p.samples[key] = sm
+		p.order = append(p.order, key)
+	}
+	sm.count++
+	sm.self += self
+
+	p.stack = p.stack[:depth]
+}
+
+// frameName labels a statement in stacks; the line keeps identical statements apart.
+func (p *Profiler) frameName(stmt ast.Stmt) string {
+	return fmt.Sprintf("%v (line %v)", p.describe(stmt), ast.StmtLine(stmt))
+}
+
+// describe names a statement, or the function a block is the body of.
+func (p *Profiler) describe(stmt ast.Stmt) string {
+	if name, ok := p.bodies[stmt]; ok {
+		return name
+	}
+	return engine.Describe(stmt)
+}
+
+// Stats returns the profile of every statement executed, the most expensive first.
+func (p *Profiler) Stats() []Stat {
+	stats := make([]Stat, 0, len(p.stats))
+	for _, s := range p.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Self != stats[j].Self {
+			return stats[i].Self > stats[j].Self
+		}
+		return stats[i].Line < stats[j].Line
+	})
+	return stats
+}
+
+// WriteTable writes the n most expensive statements, or all of them when n is not positive, as a table.
+func (p *Profiler) WriteTable(w io.Writer, n int) error {
+	stats := p.Stats()
+	if n > 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "self\tself%%\ttotal\ttotal%%\tcount\tline\t  statement\n")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%v\t%.1f%%\t%v\t%.1f%%\t%v\t%v\t  %v\n",
+			s.Self, p.percent(s.Self), s.Total, p.percent(s.Total), s.Count, s.Line, s.Name)
+	}
+	return tw.Flush()
+}
+
+func (p *Profiler) percent(d time.Duration) float64 {
+	if p.duration == 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(p.duration)
+}
+
+// WriteFolded writes the profile in the folded stack format read by flamegraph tools: one line per call stack, its
+// frames separated by semicolons and followed by the stack's self time in microseconds.
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	for _, key := range p.order {
+		if _, err := fmt.Fprintf(w, "%v %v\n", key, p.samples[key].self.Microseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
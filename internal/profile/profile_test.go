@@ -0,0 +1,311 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/profile"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+const fibSource = `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+var i = 0;
+while (i < 2) i = i + 1;
+print fib(3);
+`
+
+// fibStacks are the call stacks of fibSource, in the order their top statements first finish.
+var fibStacks = []string{
+	"fun fib (line 1)",
+	"var i (line 5)",
+	"while (line 6);expression (line 6)",
+	"while (line 6)",
+	"print (line 7);fib() (line 1);if (line 2)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);if (line 2)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);return (line 3);fib() (line 1);if (line 2);return (line 2)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);return (line 3);fib() (line 1);if (line 2)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);return (line 3);fib() (line 1)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);return (line 3)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1)",
+	"print (line 7);fib() (line 1);return (line 3);fib() (line 1);if (line 2);return (line 2)",
+	"print (line 7);fib() (line 1);return (line 3)",
+	"print (line 7);fib() (line 1)",
+	"print (line 7)",
+}
+
+func parseSource(t *testing.T, source string) []ast.Stmt {
+	t.Helper()
+	tokens, err := scan.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+func profileFib(t *testing.T) *profile.Profiler {
+	t.Helper()
+	p := profile.New("fib.lox")
+	if _, err := p.Run(parseSource(t, fibSource), engine.WithStdout(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestStats(t *testing.T) {
+	p := profileFib(t)
+	var got []string
+	for _, s := range p.Stats() {
+		got = append(got, fmt.Sprintf("%v line %v: %v", s.Name, s.Line, s.Count))
+		if s.Self < 0 || s.Self > s.Total {
+			t.Errorf("%v line %v: self %v, total %v", s.Name, s.Line, s.Self, s.Total)
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"expression line 6: 2",
+		"fib() line 1: 5",
+		"fun fib line 1: 1",
+		"if line 2: 5",
+		"print line 7: 1",
+		"return line 2: 3",
+		"return line 3: 2",
+		"var i line 5: 1",
+		"while line 6: 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	p := profileFib(t)
+	var b strings.Builder
+	if err := p.WriteTable(&b, 3); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 4 || strings.Join(strings.Fields(lines[0]), " ") != "self self% total total% count line statement" {
+		t.Fatalf("WriteTable() wrote\n%v\nwant a header and 3 rows", b.String())
+	}
+	for n, s := range p.Stats()[:3] {
+		fields := strings.Fields(lines[n+1])
+		want := []string{strconv.Itoa(s.Count), strconv.Itoa(s.Line), s.Name}
+		if len(fields) < 7 || !reflect.DeepEqual(append(fields[4:6], strings.Join(fields[6:], " ")), want) {
+			t.Errorf("row %v is %q, want count, line and statement %q", n+1, lines[n+1], want)
+		}
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	p := profileFib(t)
+	var b strings.Builder
+	if err := p.WriteFolded(&b); err != nil {
+		t.Fatal(err)
+	}
+	var stacks []string
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		if _, err := strconv.Atoi(line[i+1:]); err != nil {
+			t.Errorf("line %q does not end in a time", line)
+		}
+		stacks = append(stacks, line[:i])
+	}
+	if !reflect.DeepEqual(stacks, fibStacks) {
+		t.Errorf("WriteFolded() stacks are\n%v\nwant\n%v", strings.Join(stacks, "\n"), strings.Join(fibStacks, "\n"))
+	}
+}
+
+// protoField is a field of a protocol buffer message: a varint, or the bytes of a length-delimited field.
+type protoField struct {
+	tag    int
+	varint uint64
+	bytes  []byte
+}
+
+func decodeProto(t *testing.T, data []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		f := protoField{tag: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			f.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %v", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func decodePacked(data []byte) []uint64 {
+	var xs []uint64
+	for len(data) > 0 {
+		x, n := binary.Uvarint(data)
+		xs, data = append(xs, x), data[n:]
+	}
+	return xs
+}
+
+func TestWritePprof(t *testing.T) {
+	p := profileFib(t)
+	var b bytes.Buffer
+	if err := p.WritePprof(&b); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the string table, the functions' names and lines, the locations' functions, and the samples
+	var strs []string
+	type function struct{ name, file, line uint64 }
+	functions := make(map[uint64]function)
+	locations := make(map[uint64]uint64)
+	var samples [][2][]uint64
+	var types [][]protoField
+	for _, f := range decodeProto(t, data) {
+		switch f.tag {
+		case 1:
+			types = append(types, decodeProto(t, f.bytes))
+		case 2:
+			var s [2][]uint64
+			for _, sf := range decodeProto(t, f.bytes) {
+				s[sf.tag-1] = decodePacked(sf.bytes)
+			}
+			samples = append(samples, s)
+		case 4:
+			var id uint64
+			for _, lf := range decodeProto(t, f.bytes) {
+				switch lf.tag {
+				case 1:
+					id = lf.varint
+				case 4:
+					locations[id] = decodeProto(t, lf.bytes)[0].varint
+				}
+			}
+		case 5:
+			var id uint64
+			var fn function
+			for _, ff := range decodeProto(t, f.bytes) {
+				switch ff.tag {
+				case 1:
+					id = ff.varint
+				case 2:
+					fn.name = ff.varint
+				case 4:
+					fn.file = ff.varint
+				case 5:
+					fn.line = ff.varint
+				}
+			}
+			functions[id] = fn
+		case 6:
+			strs = append(strs, string(f.bytes))
+		}
+	}
+
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table %q does not start with the empty string", strs)
+	}
+	var sampleTypes []string
+	for _, typ := range types {
+		sampleTypes = append(sampleTypes, strs[typ[0].varint]+"/"+strs[typ[1].varint])
+	}
+	if want := []string{"count/count", "time/nanoseconds"}; !reflect.DeepEqual(sampleTypes, want) {
+		t.Errorf("sample types = %v, want %v", sampleTypes, want)
+	}
+
+	var stacks []string
+	counts := make(map[string]uint64)
+	for _, s := range samples {
+		names := make([]string, len(s[0]))
+		for i, loc := range s[0] {
+			fn := functions[locations[loc]]
+			if strs[fn.file] != "fib.lox" || !strings.HasSuffix(strs[fn.name], fmt.Sprintf("(line %v)", fn.line)) {
+				t.Errorf("function %q in %q at line %v", strs[fn.name], strs[fn.file], fn.line)
+			}
+			// locations are listed leaf first
+			names[len(names)-1-i] = strs[fn.name]
+		}
+		stack := strings.Join(names, ";")
+		stacks = append(stacks, stack)
+		counts[stack] = s[1][0]
+	}
+	if !reflect.DeepEqual(stacks, fibStacks) {
+		t.Errorf("pprof stacks are\n%v\nwant\n%v", strings.Join(stacks, "\n"), strings.Join(fibStacks, "\n"))
+	}
+	if counts["print (line 7);fib() (line 1)"] != 1 || counts["while (line 6);expression (line 6)"] != 2 {
+		t.Errorf("sample counts = %v", counts)
+	}
+}
+
+// TestGenerator checks that the body of a generator is profiled inside the statement that resumes it each time.
+func TestGenerator(t *testing.T) {
+	const source = `fun gen() { yield 1; yield 2; }
+var g = gen();
+print g.next();
+{ { print g.next(); } }
+print g.next();
+for (x in gen()) print x;
+`
+	p := profile.New("gen.lox")
+	if _, err := p.Run(parseSource(t, source), engine.WithStdout(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := p.WriteFolded(&b); err != nil {
+		t.Fatal(err)
+	}
+	stacks := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		stacks[line[:strings.LastIndexByte(line, ' ')]] = true
+	}
+	for _, want := range []string{
+		"block (line 4);block (line 4);print (line 4);gen() (line 1);yield (line 1)",
+		"print (line 5);gen() (line 1)",
+		"for x (line 6);gen() (line 1);yield (line 1)",
+	} {
+		if !stacks[want] {
+			t.Errorf("no stack %q in\n%v", want, b.String())
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, s := range p.Stats() {
+		counts[s.Name] += s.Count
+	}
+	if counts["yield"] != 4 || counts["gen()"] != 2 {
+		t.Errorf("yields = %v, generator bodies = %v, want 4 and 2", counts["yield"], counts["gen()"])
+	}
+}
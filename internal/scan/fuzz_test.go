@@ -0,0 +1,111 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/brentellingson/go-lox/internal/token"
+)
+
+// addSeeds adds the conformance scripts and the example scripts to the seed corpus of f.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{"../../testdata/conformance/*/*.lox", "../../scripts/*.lox", "../../script.lox"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(source))
+		}
+	}
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// cursor converts 1-based lines and rune columns to byte offsets in source. Positions must be asked for in order,
+// so that converting all the positions of a source is linear in its length.
+type cursor struct {
+	source string
+	starts []int // the byte offset of the start of each line
+	line   int
+	column int
+	offset int
+}
+
+func newCursor(source string) *cursor {
+	c := &cursor{source: source, starts: []int{0}}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			c.starts = append(c.starts, i+1)
+		}
+	}
+	return c
+}
+
+// seek returns the byte offset of line and column, or -1 if there is no such position.
+func (c *cursor) seek(line, column int) int {
+	if line < 1 || line > len(c.starts) {
+		return -1
+	}
+	if line != c.line || column < c.column {
+		c.line, c.column, c.offset = line, 1, c.starts[line-1]
+	}
+	for ; c.column < column; c.column++ {
+		if c.offset >= len(c.source) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(c.source[c.offset:])
+		c.offset += size
+	}
+	return c.offset
+}
+
+// FuzzScan checks that scanning never panics, and that the lexemes of the tokens and comments of source that scans
+// cleanly, in order, are the source without its whitespace, each found at its line and column.
+func FuzzScan(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		scanner := NewScanner(source)
+		tokens := scanner.ScanTokens()
+		if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.EOF {
+			t.Fatalf("tokens do not end with EOF: %v", tokens)
+		}
+		if scanner.Err() != nil {
+			return
+		}
+
+		trivia := append(append([]token.Token{}, tokens...), scanner.Comments...)
+		sort.SliceStable(trivia, func(i, j int) bool {
+			if trivia[i].Line != trivia[j].Line {
+				return trivia[i].Line < trivia[j].Line
+			}
+			return trivia[i].Column < trivia[j].Column
+		})
+		positions := newCursor(source)
+		var b strings.Builder
+		for _, tok := range trivia {
+			b.WriteString(tok.Lexeme)
+			if at := positions.seek(tok.Line, tok.Column); at < 0 || !strings.HasPrefix(source[at:], tok.Lexeme) {
+				t.Errorf("%q is not at line %v, column %v", tok.Lexeme, tok.Line, tok.Column)
+			}
+		}
+		if got, want := stripSpace(b.String()), stripSpace(source); got != want {
+			t.Errorf("lexemes reconstruct %q, want %q", got, want)
+		}
+	})
+}
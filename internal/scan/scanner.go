@@ -1,8 +1,11 @@
 package scan
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/brentellingson/go-lox/internal/token"
@@ -10,12 +13,16 @@ import (
 
 var reserved = map[string]token.TokenType{
 	"and":    token.AND,
+	"as":     token.AS,
 	"class":  token.CLASS,
 	"else":   token.ELSE,
 	"false":  token.FALSE,
 	"fun":    token.FUN,
 	"for":    token.FOR,
+	"from":   token.FROM,
 	"if":     token.IF,
+	"import": token.IMPORT,
+	"in":     token.IN,
 	"nil":    token.NIL,
 	"or":     token.OR,
 	"print":  token.PRINT,
@@ -25,6 +32,17 @@ var reserved = map[string]token.TokenType{
 	"true":   token.TRUE,
 	"var":    token.VAR,
 	"while":  token.WHILE,
+	"yield":  token.YIELD,
+}
+
+// Keywords returns the reserved words of Lox in alphabetical order.
+func Keywords() []string {
+	keywords := make([]string, 0, len(reserved))
+	for k := range reserved {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	return keywords
 }
 
 type ScanError struct {
@@ -46,12 +64,18 @@ func Scan(source string) ([]token.Token, error) {
 }
 
 type Scanner struct {
-	Source  string
-	Tokens  []token.Token
-	start   int
-	current int
-	line    int
-	errs    []error
+	Source      string
+	Tokens      []token.Token
+	Comments    []token.Token
+	start       int
+	current     int
+	line        int
+	lineStart   int // offset of the first byte of the current line
+	startLine   int // line of the token being scanned
+	startColumn int // column of the token being scanned
+	counted     int // offset on the current line up to which columns have been counted
+	column      int // column of the byte at counted
+	errs        []error
 }
 
 func NewScanner(source string) *Scanner {
@@ -64,13 +88,26 @@ func NewScanner(source string) *Scanner {
 func (s *Scanner) ScanTokens() []token.Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.startColumn = s.columnAt(s.start)
 		s.scanToken()
 	}
 
-	s.Tokens = append(s.Tokens, token.NewToken(token.EOF, "", nil, s.line))
+	s.Tokens = append(s.Tokens, token.NewToken(token.EOF, "", nil, s.line, s.columnAt(len(s.Source))))
 	return s.Tokens
 }
 
+// columnAt returns the column of offset, which must be on the current line and no earlier than the offset last asked
+// about. Counting on from there, rather than from the start of the line, keeps scanning long lines linear.
+func (s *Scanner) columnAt(offset int) int {
+	if s.counted < s.lineStart || s.column == 0 {
+		s.counted, s.column = s.lineStart, 1
+	}
+	s.column += utf8.RuneCountInString(s.Source[s.counted:offset])
+	s.counted = offset
+	return s.column
+}
+
 func (s *Scanner) scanToken() {
 	c := s.advance()
 	switch c {
@@ -100,9 +137,11 @@ func (s *Scanner) scanToken() {
 		} else {
 			s.addToken(token.BANG)
 		}
-	case '=': // EQUAL or EQUAL_EQUAL
+	case '=': // EQUAL, EQUAL_EQUAL or ARROW
 		if s.match('=') {
 			s.addToken(token.EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(token.ARROW)
 		} else {
 			s.addToken(token.EQUAL)
 		}
@@ -123,6 +162,7 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.addComment()
 		} else {
 			s.addToken(token.SLASH)
 		}
@@ -132,6 +172,7 @@ func (s *Scanner) scanToken() {
 		s.string()
 	case '\n':
 		s.line++
+		s.lineStart = s.current
 	default:
 		if s.isDigit(c) {
 			s.number()
@@ -143,12 +184,17 @@ func (s *Scanner) scanToken() {
 	}
 }
 
+// Err returns the errors encountered by ScanTokens, or nil if the source scanned cleanly.
+func (s *Scanner) Err() error {
+	return errors.Join(s.errs...)
+}
+
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
+		if s.advance() == '\n' {
 			s.line++
+			s.lineStart = s.current
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
@@ -254,10 +300,16 @@ func (s *Scanner) peekNext() rune {
 
 func (s *Scanner) addToken(tokenType token.TokenType) {
 	text := s.Source[s.start:s.current]
-	s.Tokens = append(s.Tokens, token.NewToken(tokenType, text, nil, s.line))
+	s.Tokens = append(s.Tokens, token.NewToken(tokenType, text, nil, s.startLine, s.startColumn))
 }
 
 func (s *Scanner) addTokenLiteral(tokenType token.TokenType, literal any) {
 	text := s.Source[s.start:s.current]
-	s.Tokens = append(s.Tokens, token.NewToken(tokenType, text, literal, s.line))
+	s.Tokens = append(s.Tokens, token.NewToken(tokenType, text, literal, s.startLine, s.startColumn))
+}
+
+// addComment records a comment as trivia. Comments are kept out of Tokens so the parser never sees them.
+func (s *Scanner) addComment() {
+	text := strings.TrimRight(s.Source[s.start:s.current], " \t\r")
+	s.Comments = append(s.Comments, token.NewToken(token.COMMENT, text, nil, s.startLine, s.startColumn))
 }
@@ -7,10 +7,11 @@ type Token struct {
 	Lexeme  string
 	Literal any
 	Line    int
+	Column  int // 1-based, counted in runes
 }
 
-func NewToken(tokenType TokenType, lexeme string, literal any, line int) Token {
-	return Token{tokenType, lexeme, literal, line}
+func NewToken(tokenType TokenType, lexeme string, literal any, line int, column int) Token {
+	return Token{tokenType, lexeme, literal, line, column}
 }
 
 func (t Token) String() string {
@@ -39,6 +40,7 @@ const (
 	BANG_EQUAL
 	EQUAL
 	EQUAL_EQUAL
+	ARROW
 	GREATER
 	GREATER_EQUAL
 	LESS
@@ -56,11 +58,15 @@ const (
 
 	// Keywords.
 	AND
+	AS
 	CLASS
 	ELSE
 	FUN
 	FOR
+	FROM
 	IF
+	IMPORT
+	IN
 	OR
 	PRINT
 	RETURN
@@ -68,6 +74,10 @@ const (
 	THIS
 	VAR
 	WHILE
+	YIELD
+
+	// Trivia.
+	COMMENT
 
 	// Sentinal.
 	EOF
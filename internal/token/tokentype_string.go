@@ -23,35 +23,42 @@ func _() {
 	_ = x[BANG_EQUAL-12]
 	_ = x[EQUAL-13]
 	_ = x[EQUAL_EQUAL-14]
-	_ = x[GREATER-15]
-	_ = x[GREATER_EQUAL-16]
-	_ = x[LESS-17]
-	_ = x[LESS_EQUAL-18]
-	_ = x[IDENTIFIER-19]
-	_ = x[STRING-20]
-	_ = x[NUMBER-21]
-	_ = x[TRUE-22]
-	_ = x[FALSE-23]
-	_ = x[NIL-24]
-	_ = x[AND-25]
-	_ = x[CLASS-26]
-	_ = x[ELSE-27]
-	_ = x[FUN-28]
-	_ = x[FOR-29]
-	_ = x[IF-30]
-	_ = x[OR-31]
-	_ = x[PRINT-32]
-	_ = x[RETURN-33]
-	_ = x[SUPER-34]
-	_ = x[THIS-35]
-	_ = x[VAR-36]
-	_ = x[WHILE-37]
-	_ = x[EOF-38]
+	_ = x[ARROW-15]
+	_ = x[GREATER-16]
+	_ = x[GREATER_EQUAL-17]
+	_ = x[LESS-18]
+	_ = x[LESS_EQUAL-19]
+	_ = x[IDENTIFIER-20]
+	_ = x[STRING-21]
+	_ = x[NUMBER-22]
+	_ = x[TRUE-23]
+	_ = x[FALSE-24]
+	_ = x[NIL-25]
+	_ = x[AND-26]
+	_ = x[AS-27]
+	_ = x[CLASS-28]
+	_ = x[ELSE-29]
+	_ = x[FUN-30]
+	_ = x[FOR-31]
+	_ = x[FROM-32]
+	_ = x[IF-33]
+	_ = x[IMPORT-34]
+	_ = x[IN-35]
+	_ = x[OR-36]
+	_ = x[PRINT-37]
+	_ = x[RETURN-38]
+	_ = x[SUPER-39]
+	_ = x[THIS-40]
+	_ = x[VAR-41]
+	_ = x[WHILE-42]
+	_ = x[YIELD-43]
+	_ = x[COMMENT-44]
+	_ = x[EOF-45]
 }
 
-const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERTRUEFALSENILANDCLASSELSEFUNFORIFORPRINTRETURNSUPERTHISVARWHILEEOF"
+const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALARROWGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERTRUEFALSENILANDASCLASSELSEFUNFORFROMIFIMPORTINORPRINTRETURNSUPERTHISVARWHILEYIELDCOMMENTEOF"
 
-var _TokenType_index = [...]uint8{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 114, 127, 131, 141, 151, 157, 163, 167, 172, 175, 178, 183, 187, 190, 193, 195, 197, 202, 208, 213, 217, 220, 225, 228}
+var _TokenType_index = [...]uint16{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 112, 119, 132, 136, 146, 156, 162, 168, 172, 177, 180, 183, 185, 190, 194, 197, 200, 204, 206, 212, 214, 216, 221, 227, 232, 236, 239, 244, 249, 256, 259}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brentellingson/go-lox/internal/ast"
+	"github.com/brentellingson/go-lox/internal/format"
+)
+
+// TreeNode is a generic view of a syntax tree node, used by the tree and DOT renderings of a program.
+type TreeNode struct {
+	Kind     string
+	Label    string
+	Role     string
+	Line     int
+	Children []*TreeNode
+}
+
+// BuildTree returns a Program node whose children are the statements of the program.
+func BuildTree(stmts []ast.Stmt) *TreeNode {
+	b := &treeBuilder{}
+	root := &TreeNode{Kind: "Program"}
+	for _, stmt := range stmts {
+		root.Children = append(root.Children, b.stmt("", stmt))
+	}
+	return root
+}
+
+// PrintTree renders the program as an indented outline, one node per line.
+func PrintTree(stmts []ast.Stmt) string {
+	var b strings.Builder
+	var walk func(n *TreeNode, depth int)
+	walk = func(n *TreeNode, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		if n.Role != "" {
+			b.WriteString(n.Role + ": ")
+		}
+		b.WriteString(n.Kind)
+		if n.Label != "" {
+			b.WriteString(" " + n.Label)
+		}
+		if n.Line > 0 {
+			fmt.Fprintf(&b, " (line %v)", n.Line)
+		}
+		b.WriteRune('\n')
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	walk(BuildTree(stmts), 0)
+	return b.String()
+}
+
+// PrintDot renders the program as a Graphviz digraph.
+func PrintDot(stmts []ast.Stmt) string {
+	var b strings.Builder
+	b.WriteString("digraph ast {\n")
+	b.WriteString("  node [shape=box, fontname=monospace];\n")
+	id := 0
+	var walk func(n *TreeNode) int
+	walk = func(n *TreeNode) int {
+		self := id
+		id++
+		label := dotEscape(n.Kind)
+		if n.Label != "" {
+			label += `\n` + dotEscape(n.Label)
+		}
+		fmt.Fprintf(&b, "  n%v [label=\"%v\"];\n", self, label)
+		for _, c := range n.Children {
+			child := walk(c)
+			if c.Role != "" {
+				fmt.Fprintf(&b, "  n%v -> n%v [label=\"%v\"];\n", self, child, dotEscape(c.Role))
+			} else {
+				fmt.Fprintf(&b, "  n%v -> n%v;\n", self, child)
+			}
+		}
+		return self
+	}
+	walk(BuildTree(stmts))
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+type treeBuilder struct{}
+
+func (b *treeBuilder) stmt(role string, stmt ast.Stmt) *TreeNode {
+	v, _ := stmt.Accept(b)
+	n := v.(*TreeNode)
+	n.Role = role
+	return n
+}
+
+func (b *treeBuilder) expr(role string, expr ast.Expr) *TreeNode {
+	v, _ := expr.Accept(b)
+	n := v.(*TreeNode)
+	n.Role = role
+	return n
+}
+
+func (b *treeBuilder) VisitExpressionStmt(stmt *ast.Expression) (any, error) {
+	return &TreeNode{Kind: "Expression", Line: ast.StmtLine(stmt), Children: []*TreeNode{b.expr("", stmt.Expression)}}, nil
+}
+
+func (b *treeBuilder) VisitPrintStmt(stmt *ast.Print) (any, error) {
+	return &TreeNode{Kind: "Print", Line: stmt.Keyword.Line, Children: []*TreeNode{b.expr("", stmt.Expression)}}, nil
+}
+
+func (b *treeBuilder) VisitVarStmt(stmt *ast.Var) (any, error) {
+	n := &TreeNode{Kind: "Var", Label: stmt.Name.Lexeme, Line: stmt.Name.Line}
+	if stmt.Expression != nil {
+		n.Children = append(n.Children, b.expr("initializer", stmt.Expression))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitBlockStmt(stmt *ast.Block) (any, error) {
+	n := &TreeNode{Kind: "Block", Line: stmt.LeftBrace.Line}
+	for _, s := range stmt.Statements {
+		n.Children = append(n.Children, b.stmt("", s))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitIfStmt(stmt *ast.If) (any, error) {
+	n := &TreeNode{Kind: "If", Line: stmt.Keyword.Line}
+	n.Children = append(n.Children, b.expr("condition", stmt.Condition), b.stmt("then", stmt.ThenBranch))
+	if stmt.ElseBranch != nil {
+		n.Children = append(n.Children, b.stmt("else", stmt.ElseBranch))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitWhileStmt(stmt *ast.While) (any, error) {
+	n := &TreeNode{Kind: "While", Line: stmt.Keyword.Line}
+	n.Children = append(n.Children, b.expr("condition", stmt.Condition), b.stmt("body", stmt.Body))
+	return n, nil
+}
+
+func (b *treeBuilder) VisitImportStmt(stmt *ast.Import) (any, error) {
+	n := &TreeNode{Kind: "Import", Label: stmt.Path.Lexeme, Line: stmt.Keyword.Line}
+	if len(stmt.Names) == 0 {
+		n.Children = append(n.Children, &TreeNode{Kind: "Name", Label: stmt.Alias.Lexeme, Role: "alias", Line: stmt.Alias.Line})
+	}
+	for _, name := range stmt.Names {
+		n.Children = append(n.Children, &TreeNode{Kind: "Name", Label: name.Lexeme, Role: "name", Line: name.Line})
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitFunctionStmt(stmt *ast.Function) (any, error) {
+	n := &TreeNode{Kind: "Function", Label: stmt.Name.Lexeme, Line: stmt.Keyword.Line}
+	for _, param := range stmt.Params {
+		n.Children = append(n.Children, &TreeNode{Kind: "Name", Label: param.Lexeme, Role: "param", Line: param.Line})
+	}
+	n.Children = append(n.Children, b.stmt("body", stmt.Body))
+	return n, nil
+}
+
+func (b *treeBuilder) VisitReturnStmt(stmt *ast.Return) (any, error) {
+	n := &TreeNode{Kind: "Return", Line: stmt.Keyword.Line}
+	if stmt.Value != nil {
+		n.Children = append(n.Children, b.expr("", stmt.Value))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitForStmt(stmt *ast.For) (any, error) {
+	n := &TreeNode{Kind: "For", Line: stmt.Keyword.Line}
+	n.Children = append(n.Children,
+		&TreeNode{Kind: "Name", Label: stmt.Name.Lexeme, Role: "variable", Line: stmt.Name.Line},
+		b.expr("iterable", stmt.Iterable),
+		b.stmt("body", stmt.Body),
+	)
+	return n, nil
+}
+
+func (b *treeBuilder) VisitYieldStmt(stmt *ast.Yield) (any, error) {
+	n := &TreeNode{Kind: "Yield", Line: stmt.Keyword.Line}
+	if stmt.Value != nil {
+		n.Children = append(n.Children, b.expr("", stmt.Value))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitBinaryExpr(expr *ast.Binary) (any, error) {
+	return &TreeNode{
+		Kind:     "Binary",
+		Label:    expr.Operator.Lexeme,
+		Line:     expr.Operator.Line,
+		Children: []*TreeNode{b.expr("left", expr.Left), b.expr("right", expr.Right)},
+	}, nil
+}
+
+func (b *treeBuilder) VisitGroupingExpr(expr *ast.Grouping) (any, error) {
+	return &TreeNode{Kind: "Grouping", Children: []*TreeNode{b.expr("", expr.Expression)}}, nil
+}
+
+func (b *treeBuilder) VisitLiteralExpr(expr *ast.Literal) (any, error) {
+	return &TreeNode{Kind: "Literal", Label: format.Literal(expr.Value)}, nil
+}
+
+func (b *treeBuilder) VisitUnaryExpr(expr *ast.Unary) (any, error) {
+	return &TreeNode{
+		Kind:     "Unary",
+		Label:    expr.Operator.Lexeme,
+		Line:     expr.Operator.Line,
+		Children: []*TreeNode{b.expr("", expr.Right)},
+	}, nil
+}
+
+func (b *treeBuilder) VisitVariableExpr(expr *ast.Variable) (any, error) {
+	return &TreeNode{Kind: "Variable", Label: expr.Name.Lexeme, Line: expr.Name.Line}, nil
+}
+
+func (b *treeBuilder) VisitAssignExpr(expr *ast.Assign) (any, error) {
+	return &TreeNode{
+		Kind:     "Assign",
+		Label:    expr.Name.Lexeme,
+		Line:     expr.Name.Line,
+		Children: []*TreeNode{b.expr("value", expr.Value)},
+	}, nil
+}
+
+func (b *treeBuilder) VisitLogicalExpr(expr *ast.Logical) (any, error) {
+	return &TreeNode{
+		Kind:     "Logical",
+		Label:    expr.Operator.Lexeme,
+		Line:     expr.Operator.Line,
+		Children: []*TreeNode{b.expr("left", expr.Left), b.expr("right", expr.Right)},
+	}, nil
+}
+
+func (b *treeBuilder) VisitGetExpr(expr *ast.Get) (any, error) {
+	return &TreeNode{
+		Kind:     "Get",
+		Label:    expr.Name.Lexeme,
+		Line:     expr.Name.Line,
+		Children: []*TreeNode{b.expr("object", expr.Object)},
+	}, nil
+}
+
+func (b *treeBuilder) VisitCallExpr(expr *ast.Call) (any, error) {
+	n := &TreeNode{Kind: "Call", Line: expr.Paren.Line, Children: []*TreeNode{b.expr("callee", expr.Callee)}}
+	for _, arg := range expr.Arguments {
+		n.Children = append(n.Children, b.expr("argument", arg))
+	}
+	return n, nil
+}
+
+func (b *treeBuilder) VisitLambdaExpr(expr *ast.Lambda) (any, error) {
+	n := &TreeNode{Kind: "Lambda", Line: expr.Keyword.Line}
+	for _, param := range expr.Params {
+		n.Children = append(n.Children, &TreeNode{Kind: "Name", Label: param.Lexeme, Role: "param", Line: param.Line})
+	}
+	n.Children = append(n.Children, b.stmt("body", expr.Body))
+	return n, nil
+}
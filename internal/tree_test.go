@@ -0,0 +1,15 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal"
+)
+
+func TestPrintTree(t *testing.T) {
+	checkGolden(t, "tree", internal.PrintTree(parseProgram(t)))
+}
+
+func TestPrintDot(t *testing.T) {
+	checkGolden(t, "dot", internal.PrintDot(parseProgram(t)))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/lsp"
+)
+
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Println("Usage: go-lox lsp")
+		return 64
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -2,34 +2,109 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/brentellingson/go-lox/internal/ast"
 	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/highlight"
+	"github.com/brentellingson/go-lox/internal/optimize"
 	"github.com/brentellingson/go-lox/internal/parse"
 	"github.com/brentellingson/go-lox/internal/repl"
 	"github.com/brentellingson/go-lox/internal/scan"
+	"github.com/brentellingson/go-lox/internal/token"
 )
 
+// commands are the go-lox subcommands; each receives the arguments after its name and returns the exit status.
+var commands = map[string]func(args []string) int{
+	"fmt":       runFmt,
+	"ast":       runAst,
+	"parse":     runParse,
+	"vet":       runVet,
+	"lsp":       runLsp,
+	"highlight": runHighlight,
+	"debug":     runDebug,
+	"dap":       runDap,
+	"profile":   runProfile,
+	"cover":     runCover,
+	"test":      runTest,
+}
+
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: go-lox [script]")
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = usage
+	o0 := flag.Bool("O0", false, "disable optimizations")
+	o1 := flag.Bool("O1", true, "fold constant expressions and remove dead branches; -O1=false is the same as -O0")
+	trace := flag.Bool("trace", false, "log each statement executed, and the values it produces, to stderr")
+	timeout := flag.Duration("timeout", 0, "stop the script after running for this long")
+	maxSteps := flag.Int("max-steps", 0, "stop the script after executing this many statements")
+	maxDepth := flag.Int("max-depth", 0, "stop the script when calls nest deeper than this")
+	maxMemory := flag.Int("max-memory", 0, "stop the script after allocating this many bytes")
+	flag.Parse()
+	if flag.NArg() > 1 {
+		usage()
 		os.Exit(64)
 	}
 
-	if len(os.Args) == 2 {
-		runFile(os.Args[1])
+	level := optimize.O1
+	if *o0 || !*o1 {
+		level = optimize.O0
+	}
+	// scripts run from the command line are the user's own, and may use everything the host offers
+	opts := []engine.Option{engine.AllowAll()}
+	if *trace {
+		opts = append(opts, engine.WithTrace(os.Stderr))
+	}
+	if *timeout > 0 {
+		opts = append(opts, engine.WithTimeout(*timeout))
+	}
+	if *maxSteps > 0 {
+		opts = append(opts, engine.WithMaxSteps(*maxSteps))
+	}
+	if *maxDepth > 0 {
+		opts = append(opts, engine.WithMaxDepth(*maxDepth))
+	}
+	if *maxMemory > 0 {
+		opts = append(opts, engine.WithMaxMemory(*maxMemory))
+	}
+	if flag.NArg() == 1 {
+		runFile(flag.Arg(0), level, opts...)
 	} else {
-		runPrompt()
+		runPrompt(level, opts...)
 	}
 }
 
-func runFile(path string) {
+func usage() {
+	fmt.Println("Usage: go-lox [-O0|-O1] [-trace] [-timeout d] [-max-steps n] [-max-depth n] [-max-memory n] [script]")
+	fmt.Println("       go-lox fmt [-w] [-d] [-check] files...")
+	fmt.Println("       go-lox ast [-format sexpr|tree|json|dot] script")
+	fmt.Println("       go-lox parse [--json] script")
+	fmt.Println("       go-lox vet files...")
+	fmt.Println("       go-lox lsp")
+	fmt.Println("       go-lox highlight [-format ansi|html] script")
+	fmt.Println("       go-lox debug script")
+	fmt.Println("       go-lox dap")
+	fmt.Println("       go-lox profile [-top n] [-pprof file] [-folded file] script")
+	fmt.Println("       go-lox cover [-html file] [-lcov file] scripts...")
+	fmt.Println("       go-lox test [-run regexp] [-v] [paths...]")
+}
+
+func runFile(path string, level optimize.Level, opts ...engine.Option) {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		panic("error reading file " + path)
 	}
-	repl := repl.NewRepl(scan.Scan, parse.Parse, engine.NewInterpreter())
+	parse := optimizingParser(level)
+	opts = append(opts, engine.WithLoader(newLoader(parse)), engine.WithPath(path))
+	interpreter := engine.NewInterpreter(opts...)
+	repl := repl.NewRepl(scan.Scan, parse, interpreter)
 	_, err = repl.Run(string(bytes))
 	if err != nil {
 		fmt.Println(err)
@@ -37,8 +112,11 @@ func runFile(path string) {
 	}
 }
 
-func runPrompt() {
-	repl := repl.NewRepl(scan.Scan, parse.Parse, engine.NewInterpreter())
+func runPrompt(level optimize.Level, opts ...engine.Option) {
+	parse := optimizingParser(level)
+	interpreter := engine.NewInterpreter(append(opts, engine.WithLoader(newLoader(parse)))...)
+	repl := repl.NewRepl(scan.Scan, parse, interpreter)
+	color := isTerminal(os.Stdin) && isTerminal(os.Stdout)
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Print("> ")
@@ -46,8 +124,14 @@ func runPrompt() {
 			break
 		}
 		line := scanner.Text()
+		if color {
+			// redraw the line just typed with syntax highlighting
+			fmt.Print("\x1b[1A\r\x1b[2K> " + highlight.ANSI(line) + "\n")
+		}
 		rslt, err := repl.Run(line)
-		if err != nil {
+		if err != nil && color {
+			fmt.Println("\x1b[31m" + err.Error() + "\x1b[0m")
+		} else if err != nil {
 			fmt.Println(err)
 		} else if rslt != nil {
 			fmt.Println(rslt)
@@ -58,3 +142,36 @@ func runPrompt() {
 		panic("error reading input")
 	}
 }
+
+// newLoader returns a module loader that searches the directories listed in LOXPATH after the importing file's own.
+func newLoader(parse func(tokens []token.Token) ([]ast.Stmt, error)) *engine.Loader {
+	var searchPath []string
+	if v := os.Getenv("LOXPATH"); v != "" {
+		searchPath = filepath.SplitList(v)
+	}
+	return engine.NewLoader(scan.Scan, parse, searchPath)
+}
+
+// optimizingParser returns a parse function that runs the optimizer at level over each parsed program.
+func optimizingParser(level optimize.Level) func(tokens []token.Token) ([]ast.Stmt, error) {
+	return func(tokens []token.Token) ([]ast.Stmt, error) {
+		stmts, err := parse.Parse(tokens)
+		if err != nil {
+			return nil, err
+		}
+		return optimize.Optimize(stmts, level), nil
+	}
+}
+
+// parseFile reads, scans and parses the script at path.
+func parseFile(path string) ([]ast.Stmt, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := scan.Scan(string(bytes))
+	if err != nil {
+		return nil, err
+	}
+	return parse.Parse(tokens)
+}
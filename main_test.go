@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brentellingson/go-lox/internal/parse"
+)
+
+func TestLoaderSearchesLoxpath(t *testing.T) {
+	one, two := t.TempDir(), t.TempDir()
+	t.Setenv("LOXPATH", one+string(filepath.ListSeparator)+two)
+	if got := newLoader(parse.Parse).SearchPath; !reflect.DeepEqual(got, []string{one, two}) {
+		t.Errorf("SearchPath = %q, want %q", got, []string{one, two})
+	}
+	t.Setenv("LOXPATH", "")
+	if got := newLoader(parse.Parse).SearchPath; got != nil {
+		t.Errorf("SearchPath = %q without LOXPATH, want none", got)
+	}
+}
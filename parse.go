@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/brentellingson/go-lox/internal"
+	"github.com/brentellingson/go-lox/internal/ast"
+)
+
+func runParse(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "write the syntax tree as versioned JSON")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: go-lox parse [--json] script")
+		return 64
+	}
+
+	stmts, err := parseFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if !*asJSON {
+		fmt.Print(internal.PrintStmts(stmts))
+		return 0
+	}
+	bytes, err := ast.EncodeJSON(stmts)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Print(string(bytes))
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/parse"
+	"github.com/brentellingson/go-lox/internal/profile"
+	"github.com/brentellingson/go-lox/internal/scan"
+)
+
+func runProfile(args []string) int {
+	flags := flag.NewFlagSet("profile", flag.ContinueOnError)
+	top := flags.Int("top", 20, "number of statements in the hotspot table, or 0 for all")
+	pprof := flags.String("pprof", "", "write a profile for go tool pprof to `file`")
+	folded := flags.String("folded", "", "write folded stacks for flamegraph tools to `file`")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: go-lox profile [-top n] [-pprof file] [-folded file] script")
+		return 64
+	}
+
+	path := flags.Arg(0)
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	tokens, err := scan.Scan(string(bytes))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	stmts, err := parse.Parse(tokens)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// the program's output goes to stdout, the report to stderr
+	profiler := profile.New(path)
+	status := 0
+	if _, err := profiler.Run(stmts, engine.WithLoader(newLoader(parse.Parse)), engine.WithPath(path), engine.AllowAll()); err != nil {
+		fmt.Println(err)
+		status = 1
+	}
+	if err := profiler.WriteTable(os.Stderr, *top); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *pprof != "" {
+		if err := writeFile(*pprof, profiler.WritePprof); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if *folded != "" {
+		if err := writeFile(*folded, profiler.WriteFolded); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/brentellingson/go-lox/internal/engine"
+	"github.com/brentellingson/go-lox/internal/loxtest"
+	"github.com/brentellingson/go-lox/internal/parse"
+)
+
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only the tests whose names match the regular expression")
+	verbose := flags.Bool("v", false, "list every test run, not only failures")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Println(err)
+			return 64
+		}
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := loxtest.Find(paths)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 1
+	}
+
+	status := 0
+	for _, path := range files {
+		stmts, err := parseFile(path)
+		if err != nil {
+			fmt.Printf("FAIL\t%v: %v\n", path, err)
+			status = 1
+			continue
+		}
+		results := loxtest.Run(path, stmts, filter, engine.WithLoader(newLoader(parse.Parse)), engine.AllowAll())
+		failed := false
+		for _, r := range results {
+			loxtest.WriteResult(os.Stdout, r, *verbose)
+			failed = failed || r.Err != nil
+		}
+		switch {
+		case failed:
+			fmt.Printf("FAIL\t%v\n", path)
+			status = 1
+		case len(results) == 0:
+			fmt.Printf("ok  \t%v [no tests to run]\n", path)
+		default:
+			fmt.Printf("ok  \t%v\n", path)
+		}
+	}
+	return status
+}
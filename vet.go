@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brentellingson/go-lox/internal/lint"
+)
+
+func runVet(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: go-lox vet files...")
+		return 64
+	}
+
+	status := 0
+	for _, path := range args {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+		diags, err := lint.Source(string(bytes))
+		if err != nil {
+			fmt.Printf("%v: %v\n", path, err)
+			status = 1
+			continue
+		}
+		for _, d := range diags {
+			fmt.Printf("%v:%v: %v [%v]\n", path, d.Line, d.Message, d.Rule)
+			status = 1
+		}
+	}
+	return status
+}